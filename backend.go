@@ -0,0 +1,112 @@
+// Backend.
+// Интерфейс "нижнего уровня", через который UProxy и UInterface работают с uniset-системой.
+// Основная реализация - обёртка над c++ uniset_internal_api (см. backend_cpp.go),
+// но можно подключить и любую другую (например, для тестирования объектов без запуска SM).
+// ---------
+// Если пакет собран с тегом purego, то c++-часть не используется вовсе
+// и по умолчанию backend не задан (см. backend_purego.go).
+// ---------
+package uniset
+
+import (
+	"errors"
+	"sync"
+)
+
+// ----------------------------------------------------------------------------------
+type Backend interface {
+
+	// запуск в работу
+	// pollTimeout - период обновления информации о состоянии датчиков (msec)
+	Run(pollTimeout uint) error
+
+	// завершение работы
+	Terminate()
+
+	GetValue(sid ObjectID) (int64, error)
+	SetValue(sid ObjectID, value int64, supplier ObjectID) error
+
+	// заказ уведомлений об изменении датчика
+	// (после этого изменения датчика будут приходить через WaitMessage)
+	AskSensor(sid ObjectID) error
+
+	// отказ от уведомлений об изменении датчика
+	UnaskSensor(sid ObjectID) error
+
+	// ожидание сообщения об изменении датчика
+	// timeout - msec. Если за это время сообщений не было, возвращается false
+	WaitMessage(timeout uint) (*SensorEvent, bool)
+}
+
+// ----------------------------------------------------------------------------------
+var errNoBackend = errors.New("(uniset): backend is not defined")
+
+var (
+	defBackend Backend
+	defmutex   sync.RWMutex
+)
+
+// ----------------------------------------------------------------------------------
+// Задать backend используемый по умолчанию (UInterface, DoReadInputs)
+func SetDefaultBackend(b Backend) {
+	defmutex.Lock()
+	defer defmutex.Unlock()
+	defBackend = b
+}
+
+// ----------------------------------------------------------------------------------
+// Получить backend используемый по умолчанию.
+// Если он не был задан явно, создаётся штатный (см. newBackend)
+func DefaultBackend() Backend {
+
+	defmutex.RLock()
+	b := defBackend
+	defmutex.RUnlock()
+
+	if b != nil {
+		return b
+	}
+
+	defmutex.Lock()
+	defer defmutex.Unlock()
+
+	if defBackend == nil {
+		defBackend = newBackend("")
+	}
+
+	return defBackend
+}
+
+// ----------------------------------------------------------------------------------
+// заглушка используемая, если backend не доступен
+type nullBackend struct {
+}
+
+func (b *nullBackend) Run(pollTimeout uint) error {
+	return errNoBackend
+}
+
+func (b *nullBackend) Terminate() {
+}
+
+func (b *nullBackend) GetValue(sid ObjectID) (int64, error) {
+	return 0, errNoBackend
+}
+
+func (b *nullBackend) SetValue(sid ObjectID, value int64, supplier ObjectID) error {
+	return errNoBackend
+}
+
+func (b *nullBackend) AskSensor(sid ObjectID) error {
+	return errNoBackend
+}
+
+func (b *nullBackend) UnaskSensor(sid ObjectID) error {
+	return errNoBackend
+}
+
+func (b *nullBackend) WaitMessage(timeout uint) (*SensorEvent, bool) {
+	return nil, false
+}
+
+// ----------------------------------------------------------------------------------
@@ -0,0 +1,249 @@
+//go:build !purego
+
+// Реализация Backend через c++ uniset_internal_api входящий в состав uniset
+package uniset
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+	"uniset_internal_api"
+)
+
+// ----------------------------------------------------------------------------------
+// Backend работающий через c++-ый UProxy.
+// До вызова Run() запросы GetValue/SetValue идут напрямую через uniset_internal_api,
+// после запуска - через созданный c++-объект.
+//
+// ВНИМАНИЕ: заказ датчиков для этого backend-а НЕ реализован.
+// c++-ый UProxy (uniset_internal_api) не умеет ни заказывать датчики, ни отказываться от заказа,
+// поэтому c++-часть как и раньше опрашивает датчики с периодом pollTimeout (см. Run),
+// а AskSensor/UnaskSensor лишь отмечают датчик, и уведомления по незаказанным датчикам
+// отфильтровываются здесь (см. WaitMessage). Подписка без опроса работает только
+// для backend-ов, которые её поддерживают (например SMBackend).
+type CppBackend struct {
+	name   string
+	uproxy uniset_internal_api.UProxy
+	initOK bool
+	asked  map[ObjectID]bool
+	askmut sync.RWMutex
+}
+
+// ----------------------------------------------------------------------------------
+// name - имя используемое для создания c++-объекта
+func NewCppBackend(name string) *CppBackend {
+	return &CppBackend{name: name, asked: make(map[ObjectID]bool)}
+}
+
+// ----------------------------------------------------------------------------------
+func (b *CppBackend) Run(pollTimeout uint) error {
+
+	if b.initOK {
+		return nil
+	}
+
+	if !uniset_internal_api.IsUniSetInitOK() {
+		return errors.New("Not uniset init...")
+	}
+
+	if len(b.name) == 0 {
+		return errors.New("(CppBackend): unknown name for c++ proxy object")
+	}
+
+	b.uproxy = uniset_internal_api.NewUProxy(b.name)
+	b.uproxy.Run(int(pollTimeout))
+	b.initOK = true
+	return nil
+}
+
+// ----------------------------------------------------------------------------------
+func (b *CppBackend) Terminate() {
+
+	if b.initOK {
+		b.uproxy.Terminate()
+	}
+}
+
+// ----------------------------------------------------------------------------------
+func (b *CppBackend) GetValue(sid ObjectID) (int64, error) {
+
+	if !b.initOK {
+		ret := uniset_internal_api.GetValue(int64(sid))
+		if !ret.GetOk() {
+			return 0, errors.New(ret.GetErr())
+		}
+		return ret.GetValue(), nil
+	}
+
+	ret := b.uproxy.SafeGetValue(int64(sid))
+	if !ret.GetOk() {
+		return 0, errors.New(ret.GetErr())
+	}
+
+	return ret.GetValue(), nil
+}
+
+// ----------------------------------------------------------------------------------
+func (b *CppBackend) SetValue(sid ObjectID, value int64, supplier ObjectID) error {
+
+	if !b.initOK {
+		ret := uniset_internal_api.SetValue(int64(sid), value, int64(supplier))
+		if !ret.GetOk() {
+			return errors.New(ret.GetErr())
+		}
+		return nil
+	}
+
+	ret := b.uproxy.SafeSetValue(int64(sid), value)
+	if !ret.GetOk() {
+		return errors.New(ret.GetErr())
+	}
+	return nil
+}
+
+// ----------------------------------------------------------------------------------
+func (b *CppBackend) AskSensor(sid ObjectID) error {
+
+	if !b.initOK {
+		return errors.New("(CppBackend): AskSensor: c++ proxy is not running")
+	}
+
+	// На текущий момент uniset_internal_api.UProxy
+	// не поддерживает заказ датчиков (нет SafeAskSensor),
+	// уведомления приходят по опросу c++-частью (см. pollTimeout в Run).
+	// Поэтому здесь только отмечаем датчик как заказанный,
+	// а лишние уведомления отфильтровываются в WaitMessage
+	b.askmut.Lock()
+	b.asked[sid] = true
+	b.askmut.Unlock()
+	return nil
+}
+
+// ----------------------------------------------------------------------------------
+func (b *CppBackend) UnaskSensor(sid ObjectID) error {
+
+	b.askmut.Lock()
+	defer b.askmut.Unlock()
+
+	if _, found := b.asked[sid]; found {
+		b.asked[sid] = false
+	}
+
+	return nil
+}
+
+// ----------------------------------------------------------------------------------
+func (b *CppBackend) WaitMessage(timeout uint) (*SensorEvent, bool) {
+
+	if !b.initOK {
+		return nil, false
+	}
+
+	m := b.uproxy.SafeWaitMessage(int(timeout))
+	if !m.GetOk() {
+		return nil, false
+	}
+
+	msg := makeSensorEvent(m.GetSinfo())
+
+	b.askmut.RLock()
+	ask := b.asked[msg.Id]
+	b.askmut.RUnlock()
+
+	if !ask {
+		return nil, false
+	}
+
+	return msg, true
+}
+
+// ----------------------------------------------------------------------------------
+func makeSensorEvent(m uniset_internal_api.ShortIOInfo) *SensorEvent {
+	var msg SensorEvent
+	msg.Id = ObjectID(m.GetId())
+	msg.Value = m.GetValue()
+	msg.Timestamp = time.Unix(m.GetTv_sec(), m.GetTv_nsec())
+	return &msg
+}
+
+// ----------------------------------------------------------------------------------
+func newBackend(name string) Backend {
+	return NewCppBackend(name)
+}
+
+// ----------------------------------------------------------------------------------
+func isInitOK() bool {
+	return uniset_internal_api.IsUniSetInitOK()
+}
+
+// ----------------------------------------------------------------------------------
+func initialize(confile string) error {
+
+	cmdline := uniset_internal_api.ParamsInst()
+
+	for _, p := range os.Args {
+		cmdline.Add_str(p)
+	}
+
+	err := uniset_internal_api.Uniset_init_params(cmdline, confile)
+	if !err.GetOk() {
+		return errors.New(err.GetErr())
+	}
+
+	// go-часть конфигурации (SensorInfo и т.п.) не обязательна для работы c++-части
+	// поэтому ошибка загрузки не считается фатальной (см. DefaultConfig)
+	loadDefaultConfig(confile)
+	return nil
+}
+
+// ----------------------------------------------------------------------------------
+// Если c++-часть не проинициализирована (Init не вызывался),
+// используется конфигурация заданная через SetDefaultConfig (например в тестах)
+func getSensorID(name string) ObjectID {
+
+	if !uniset_internal_api.IsUniSetInitOK() {
+		return sensorIDFromConfig(name)
+	}
+
+	return ObjectID(uniset_internal_api.GetSensorID(name))
+}
+
+// ----------------------------------------------------------------------------------
+func getObjectID(name string) ObjectID {
+
+	if !uniset_internal_api.IsUniSetInitOK() {
+		return objectIDFromConfig(name)
+	}
+
+	return ObjectID(uniset_internal_api.GetObjectID(name))
+}
+
+// ----------------------------------------------------------------------------------
+func getConfigParams(name string, section string) (*UConfig, error) {
+
+	if !uniset_internal_api.IsUniSetInitOK() {
+		return configParamsFromConfig(name, section)
+	}
+
+	jstr := uniset_internal_api.GetConfigParamsByName(name, section)
+
+	if len(jstr) == 0 {
+		return nil, errors.New(fmt.Sprintf("(GetConfigParamsByName): Not found config section <%s name='%s'..> read error", section, name))
+	}
+
+	cfg := UConfig{}
+	cfg.Name = name
+	bytes := []byte(jstr)
+
+	err := json.Unmarshal(bytes, &cfg)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("(GetConfigParamsByName): error: %s", err))
+	}
+
+	return &cfg, nil
+}
+
+// ----------------------------------------------------------------------------------
@@ -0,0 +1,78 @@
+//go:build purego
+
+// Сборка без c++-части (тег purego).
+// При вызове Init(confile) конфигурация разбирается пакетом config,
+// а в качестве backend-а по умолчанию используется эмулятор SharedMemory (см. smemory.go).
+// Если Init не вызывался, backend необходимо задать явно (см. SetDefaultBackend, NewUProxyWithBackend)
+package uniset
+
+import (
+	"sync"
+)
+
+var (
+	defSM    *SMemory
+	defSMmut sync.RWMutex
+)
+
+// ----------------------------------------------------------------------------------
+// Эмулятор SharedMemory созданный при вызове Init()
+// (nil - если Init() не вызывался)
+func DefaultSMemory() *SMemory {
+	defSMmut.RLock()
+	defer defSMmut.RUnlock()
+	return defSM
+}
+
+// ----------------------------------------------------------------------------------
+func newBackend(name string) Backend {
+
+	sm := DefaultSMemory()
+	if sm == nil {
+		return &nullBackend{}
+	}
+
+	return sm.NewBackend()
+}
+
+// ----------------------------------------------------------------------------------
+func isInitOK() bool {
+	return DefaultSMemory() != nil
+}
+
+// ----------------------------------------------------------------------------------
+func initialize(confile string) error {
+
+	if err := loadDefaultConfig(confile); err != nil {
+		return err
+	}
+
+	sm, err := NewSMemoryFromConfig(DefaultConfig())
+	if err != nil {
+		return err
+	}
+
+	defSMmut.Lock()
+	defSM = sm
+	defSMmut.Unlock()
+
+	SetDefaultBackend(sm.NewBackend())
+	return nil
+}
+
+// ----------------------------------------------------------------------------------
+func getSensorID(name string) ObjectID {
+	return sensorIDFromConfig(name)
+}
+
+// ----------------------------------------------------------------------------------
+func getObjectID(name string) ObjectID {
+	return objectIDFromConfig(name)
+}
+
+// ----------------------------------------------------------------------------------
+func getConfigParams(name string, section string) (*UConfig, error) {
+	return configParamsFromConfig(name, section)
+}
+
+// ----------------------------------------------------------------------------------
@@ -0,0 +1,326 @@
+// Связывание полей структуры с датчиками по тегам (без генерации кода).
+// Формат тега:
+//
+//	uniset:"in,prop=input1"                 - вход, имя датчика берётся из свойства input1 (см. InitSensorID)
+//	uniset:"in,prop=input1,default=AI20_S"  - то же, но с именем датчика по умолчанию
+//	uniset:"out,sensor=Output_S"            - выход, датчик задан по имени
+//	uniset:"in,sensor=AI23_S,precision=2"   - для float-полей можно явно указать точность
+//
+// Поддерживаются поля типов bool, целочисленных (в том числе "перечислений" вида type Mode int)
+// и float32/float64. Для float-полей без явно заданной точности используется атрибут precision
+// датчика из configure.xml (если конфигурация загружена).
+// Вложенные (анонимные) структуры, в том числе по указателю, обходятся рекурсивно
+// (пустой указатель на структуру с тегами считается ошибкой).
+// ---------
+// Пример:
+//
+//	type MyObject struct {
+//		Level  int64 `uniset:"in,prop=level"`
+//		Alarm  bool  `uniset:"out,sensor=Alarm_S"`
+//	}
+//
+//	inputs, outputs, err := uniset.Bind(&obj, cfg)
+//	...
+//	uniset.DoUpdateInputs(&inputs, sm)
+//	uniset.DoUpdateOutputs(&outputs, cmdchannel)
+//
+// ---------
+package uniset
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ----------------------------------------------------------------------------------
+// разобранный тег uniset:"..."
+type bindTag struct {
+	output    bool
+	prop      string
+	sensor    string
+	defval    string
+	precision int // -1 - не задана
+}
+
+// ----------------------------------------------------------------------------------
+// Связывание полей структуры obj (указатель на структуру) с датчиками
+// Возвращает списки входов и выходов для использования в Do*-функциях
+func Bind(obj interface{}, cfg *UConfig) (inputs []Binding, outputs []Binding, err error) {
+
+	rv := reflect.ValueOf(obj)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return nil, nil, errors.New("(uniset.Bind): obj must be a non-nil pointer to struct")
+	}
+
+	err = bindStruct(rv.Elem(), cfg, &inputs, &outputs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return inputs, outputs, nil
+}
+
+// ----------------------------------------------------------------------------------
+func bindStruct(sv reflect.Value, cfg *UConfig, inputs *[]Binding, outputs *[]Binding) error {
+
+	st := sv.Type()
+
+	for i := 0; i < st.NumField(); i++ {
+
+		f := st.Field(i)
+		fv := sv.Field(i)
+
+		tagstr, found := f.Tag.Lookup("uniset")
+
+		if !found {
+			if !f.Anonymous {
+				continue
+			}
+
+			if fv.Kind() == reflect.Struct {
+				if err := bindStruct(fv, cfg, inputs, outputs); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if fv.Kind() == reflect.Ptr && fv.Type().Elem().Kind() == reflect.Struct {
+
+				if fv.IsNil() {
+					// пустой указатель допустим, только если связывать в нём нечего
+					if hasBindTags(fv.Type().Elem(), make(map[reflect.Type]bool)) {
+						return fmt.Errorf("(uniset.Bind): embedded field '%s' is nil", f.Name)
+					}
+					continue
+				}
+
+				if err := bindStruct(fv.Elem(), cfg, inputs, outputs); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		if !f.IsExported() {
+			return fmt.Errorf("(uniset.Bind): field '%s' is not exported", f.Name)
+		}
+
+		tag, err := parseBindTag(tagstr)
+		if err != nil {
+			return fmt.Errorf("(uniset.Bind): field '%s': %s", f.Name, err)
+		}
+
+		b, err := newFieldBinding(fv, tag, cfg)
+		if err != nil {
+			return fmt.Errorf("(uniset.Bind): field '%s': %s", f.Name, err)
+		}
+
+		if tag.output {
+			*outputs = append(*outputs, b)
+		} else {
+			*inputs = append(*inputs, b)
+		}
+	}
+
+	return nil
+}
+
+// ----------------------------------------------------------------------------------
+// есть ли в структуре (с учётом вложенных) поля с тегом uniset
+// seen - уже просмотренные типы (защита от циклов через указатели)
+func hasBindTags(st reflect.Type, seen map[reflect.Type]bool) bool {
+
+	if seen[st] {
+		return false
+	}
+	seen[st] = true
+
+	for i := 0; i < st.NumField(); i++ {
+
+		f := st.Field(i)
+		if _, found := f.Tag.Lookup("uniset"); found {
+			return true
+		}
+
+		if !f.Anonymous {
+			continue
+		}
+
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+
+		if ft.Kind() == reflect.Struct && hasBindTags(ft, seen) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ----------------------------------------------------------------------------------
+func parseBindTag(s string) (*bindTag, error) {
+
+	tag := bindTag{precision: -1}
+	parts := strings.Split(s, ",")
+
+	switch strings.TrimSpace(parts[0]) {
+	case "in":
+		tag.output = false
+	case "out":
+		tag.output = true
+	default:
+		return nil, fmt.Errorf("bad tag '%s' (must begin with 'in' or 'out')", s)
+	}
+
+	for _, p := range parts[1:] {
+
+		kv := strings.SplitN(strings.TrimSpace(p), "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("bad tag option '%s'", p)
+		}
+
+		switch kv[0] {
+		case "prop":
+			tag.prop = kv[1]
+		case "sensor":
+			tag.sensor = kv[1]
+		case "default":
+			tag.defval = kv[1]
+		case "precision":
+			prec, err := strconv.Atoi(kv[1])
+			if err != nil || prec < 0 {
+				return nil, fmt.Errorf("bad precision '%s'", kv[1])
+			}
+			tag.precision = prec
+		default:
+			return nil, fmt.Errorf("unknown tag option '%s'", kv[0])
+		}
+	}
+
+	if len(tag.prop) == 0 && len(tag.sensor) == 0 {
+		return nil, errors.New("'prop' or 'sensor' must be specified")
+	}
+
+	if len(tag.prop) > 0 && len(tag.sensor) > 0 {
+		return nil, errors.New("only one of 'prop' or 'sensor' can be specified")
+	}
+
+	return &tag, nil
+}
+
+// ----------------------------------------------------------------------------------
+func newFieldBinding(fv reflect.Value, tag *bindTag, cfg *UConfig) (*fieldBinding, error) {
+
+	var sid ObjectID
+	var name string
+
+	if len(tag.prop) > 0 {
+		var err error
+		name, err = ReadPropValue(cfg, tag.prop, tag.defval)
+		if err != nil {
+			return nil, err
+		}
+		if len(name) == 0 {
+			return nil, fmt.Errorf("sensor name for property '%s' is not specified", tag.prop)
+		}
+		sid = getSensorID(name)
+	} else {
+		name = tag.sensor
+		sid = getSensorID(tag.sensor)
+	}
+
+	if sid == DefaultObjectID {
+		return nil, fmt.Errorf("unknown sensor '%s'", name)
+	}
+
+	if !fv.CanSet() {
+		return nil, errors.New("field can not be set")
+	}
+
+	switch fv.Kind() {
+	case reflect.Bool:
+		return newFieldValue[bool](fv, sid, BoolCodec{}), nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return newFieldValue[int64](fv, sid, IntCodec[int64]{}), nil
+
+	case reflect.Float32, reflect.Float64:
+
+		prec := tag.precision
+		if prec < 0 {
+			prec = 0
+			if conf := DefaultConfig(); conf != nil {
+				if si, err := SensorInfoFromConfig(conf, sid); err == nil {
+					if prec, err = si.Precision(); err != nil {
+						return nil, err
+					}
+				}
+			}
+		}
+		if fv.Kind() == reflect.Float32 {
+			return newFieldValue[float32](fv, sid, NewFloatCodec[float32](prec)), nil
+		}
+		return newFieldValue[float64](fv, sid, NewFloatCodec[float64](prec)), nil
+	}
+
+	return nil, fmt.Errorf("unsupported type %s", fv.Type())
+}
+
+// ----------------------------------------------------------------------------------
+// Binding для поля структуры.
+// Преобразования и отслеживание изменений делает Value[T] (с тем же Codec,
+// что и для обычных полей), но над копией значения поля:
+// тип поля может быть любым совместимым с T (int32, type Mode int, float32 и т.п.),
+// копия и поле синхронизируются через reflect.
+type fieldBinding struct {
+	sid   ObjectID
+	val   Binding // Value[T] над копией поля
+	load  func()  // копия --> поле
+	store func()  // поле --> копия
+}
+
+func newFieldValue[T any](fv reflect.Value, sid ObjectID, codec Codec[T]) *fieldBinding {
+
+	b := &fieldBinding{sid: sid}
+
+	val := new(T)
+	vt := reflect.TypeOf(val).Elem()
+
+	b.load = func() { fv.Set(reflect.ValueOf(*val).Convert(fv.Type())) }
+	b.store = func() { *val = fv.Convert(vt).Interface().(T) }
+
+	b.store()
+	b.val = NewValue(&b.sid, val, codec)
+	return b
+}
+
+func (b *fieldBinding) SensorID() ObjectID {
+	return b.sid
+}
+
+func (b *fieldBinding) SetFromSM(value int64, tm time.Time) {
+	b.val.SetFromSM(value, tm)
+	b.load()
+}
+
+func (b *fieldBinding) ValueForSM() int64 {
+	b.store()
+	return b.val.ValueForSM()
+}
+
+func (b *fieldBinding) Changed() bool {
+	b.store()
+	return b.val.Changed()
+}
+
+func (b *fieldBinding) Commit() {
+	b.store()
+	b.val.Commit()
+}
@@ -0,0 +1,314 @@
+// Связывание датчиков с полями структуры (входы и выходы объекта).
+// Binding - общий интерфейс для всех типов связывания,
+// благодаря чему Do*-функции (DoUpdateInputs, DoUpdateOutputs, ...) могут работать
+// как со списками одного типа (например []*Int64Value), так и со смешанными списками ([]Binding).
+// ---------
+// Value[T] - обобщённая реализация Binding для поля типа T.
+// Преобразование между значением в SM (int64) и типом T делает Codec:
+//   - BoolCodec     - bool (в SM записывается 1 или 0)
+//   - IntCodec[T]   - целочисленные типы
+//   - FloatCodec[T] - float32/float64 с точностью precision
+//   - EnumCodec[T]  - перечисления (целочисленные типы с ограниченным набором значений)
+//
+// BoolValue, Int64Value и Float64Value - синонимы для Value[bool], Value[int64], Value[float64]
+// ---------
+package uniset
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unsafe"
+)
+
+// ----------------------------------------------------------------------------------
+type Binding interface {
+
+	// идентификатор датчика
+	SensorID() ObjectID
+
+	// обновление поля по значению из SM (для входов)
+	// tm - время изменения датчика
+	SetFromSM(value int64, tm time.Time)
+
+	// значение поля в представлении SM (для выходов)
+	ValueForSM() int64
+
+	// изменилось ли поле относительно последнего обмена с SM
+	Changed() bool
+
+	// запомнить текущее значение поля как переданное в SM
+	Commit()
+}
+
+// ----------------------------------------------------------------------------------
+// Преобразование значений между SM (int64) и типом T
+type Codec[T any] interface {
+	Decode(value int64) T // из SM
+	Encode(val T) int64   // в SM
+}
+
+// ----------------------------------------------------------------------------------
+// связывание sensor id и поля структуры типа T
+// Изменение выхода определяется в единицах SM (т.е. после Encode)
+type Value[T any] struct {
+	Sid    *ObjectID
+	Val    *T
+	prev   T
+	prevSM int64
+	tm     time.Time
+	codec  Codec[T]
+	cal    *Calibration
+}
+
+// ----------------------------------------------------------------------------------
+func NewValue[T any](sid *ObjectID, val *T, codec Codec[T]) *Value[T] {
+
+	v := &Value[T]{Sid: sid, Val: val, codec: codec}
+	v.prev = *val
+	v.prevSM = v.ValueForSM()
+	return v
+}
+
+// ----------------------------------------------------------------------------------
+// Задать калибровку для входа (выхода)
+// значения полученные из SM будут преобразовываться по диаграмме (до Decode),
+// а значения для записи в SM - обратно (после Encode)
+func (v *Value[T]) SetCalibration(cal *Calibration) *Value[T] {
+	v.cal = cal
+	v.prevSM = v.ValueForSM()
+	return v
+}
+
+// ----------------------------------------------------------------------------------
+func (v *Value[T]) Codec() Codec[T] {
+	return v.codec
+}
+
+// ----------------------------------------------------------------------------------
+// Точность (для FloatCodec), для остальных 0
+func (v *Value[T]) Precision() int {
+
+	if p, ok := v.codec.(interface{ Precision() int }); ok {
+		return p.Precision()
+	}
+
+	return 0
+}
+
+// ----------------------------------------------------------------------------------
+// значение на момент последнего обмена с SM
+func (v *Value[T]) Prev() T {
+	return v.prev
+}
+
+// ----------------------------------------------------------------------------------
+// время последнего обмена с SM (нулевое, если обмена ещё не было)
+func (v *Value[T]) Timestamp() time.Time {
+	return v.tm
+}
+
+// ----------------------------------------------------------------------------------
+func (v *Value[T]) SensorID() ObjectID {
+	return *v.Sid
+}
+
+// ----------------------------------------------------------------------------------
+func (v *Value[T]) SetFromSM(value int64, tm time.Time) {
+
+	if v.cal != nil {
+		value = v.cal.GetValue(value)
+	}
+
+	*v.Val = v.codec.Decode(value)
+	v.prev = *v.Val
+	v.prevSM = v.ValueForSM()
+	v.tm = tm
+}
+
+// ----------------------------------------------------------------------------------
+func (v *Value[T]) ValueForSM() int64 {
+
+	value := v.codec.Encode(*v.Val)
+
+	if v.cal != nil {
+		return v.cal.GetRawValue(value)
+	}
+
+	return value
+}
+
+// ----------------------------------------------------------------------------------
+func (v *Value[T]) Changed() bool {
+	return v.prevSM != v.ValueForSM()
+}
+
+// ----------------------------------------------------------------------------------
+func (v *Value[T]) Commit() {
+	v.prev = *v.Val
+	v.prevSM = v.ValueForSM()
+	v.tm = time.Now()
+}
+
+// ----------------------------------------------------------------------------------
+// Кодеки
+// ----------------------------------------------------------------------------------
+type Integer interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 | ~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64
+}
+
+type Float interface {
+	~float32 | ~float64
+}
+
+// ----------------------------------------------------------------------------------
+// В SM записывается 1 или 0, при чтении любое не нулевое значение считается true
+type BoolCodec struct {
+}
+
+func (BoolCodec) Decode(value int64) bool {
+	return value != 0
+}
+
+func (BoolCodec) Encode(val bool) int64 {
+	if val {
+		return 1
+	}
+	return 0
+}
+
+// ----------------------------------------------------------------------------------
+type IntCodec[T Integer] struct {
+}
+
+func (IntCodec[T]) Decode(value int64) T {
+	return T(value)
+}
+
+func (IntCodec[T]) Encode(val T) int64 {
+	return int64(val)
+}
+
+// ----------------------------------------------------------------------------------
+// В SM значение хранится как целое число "с точностью" precision,
+// т.е. value_in_sm = round(val * 10^precision) (округление "половина от нуля").
+// Изменения меньше последнего значащего разряда не приводят к записи в SM
+type FloatCodec[T Float] struct {
+	precision int
+	scale     float64
+}
+
+// precision - количество знаков после запятой (отрицательные значения считаются нулём)
+func NewFloatCodec[T Float](precision int) FloatCodec[T] {
+
+	if precision < 0 {
+		precision = 0
+	}
+
+	return FloatCodec[T]{precision, math.Pow10(precision)}
+}
+
+func (c FloatCodec[T]) Precision() int {
+	return c.precision
+}
+
+func (c FloatCodec[T]) Decode(value int64) T {
+
+	if c.scale == 0 {
+		return T(value)
+	}
+
+	return T(float64(value) / c.scale)
+}
+
+func (c FloatCodec[T]) Encode(val T) int64 {
+	// для float32 кратчайшее представление ищется среди float32,
+	// иначе float32(1.005) = 1.00499999523... округлится неверно
+	return roundToPrecision(float64(val), c.precision, int(unsafe.Sizeof(val))*8)
+}
+
+// ----------------------------------------------------------------------------------
+// Перечисление: допустимы только значения из списка,
+// при получении из SM недопустимого значения поле принимает значение Default
+type EnumCodec[T Integer] struct {
+	values  map[int64]bool
+	Default T
+}
+
+func NewEnumCodec[T Integer](def T, values ...T) EnumCodec[T] {
+
+	c := EnumCodec[T]{make(map[int64]bool, len(values)+1), def}
+	c.values[int64(def)] = true
+	for _, v := range values {
+		c.values[int64(v)] = true
+	}
+
+	return c
+}
+
+func (c EnumCodec[T]) Valid(val T) bool {
+	return c.values[int64(val)]
+}
+
+func (c EnumCodec[T]) Decode(value int64) T {
+
+	if !c.values[value] {
+		return c.Default
+	}
+
+	return T(value)
+}
+
+func (c EnumCodec[T]) Encode(val T) int64 {
+
+	if !c.values[int64(val)] {
+		return int64(c.Default)
+	}
+
+	return int64(val)
+}
+
+// ----------------------------------------------------------------------------------
+// округление val*10^precision до целого.
+// Простое math.Round(val*scale) ошибается на значениях вида 1.005 (1.005*100 = 100.49999...),
+// поэтому округление делается по кратчайшему десятичному представлению числа.
+// bitSize - разрядность исходного числа (32 или 64, как в strconv.FormatFloat)
+func roundToPrecision(val float64, precision int, bitSize int) int64 {
+
+	if math.IsNaN(val) || math.IsInf(val, 0) {
+		return int64(math.Round(val * math.Pow10(precision)))
+	}
+
+	s := strconv.FormatFloat(math.Abs(val), 'f', -1, bitSize)
+
+	ipart := s
+	fpart := ""
+	if dot := strings.IndexByte(s, '.'); dot >= 0 {
+		ipart = s[:dot]
+		fpart = s[dot+1:]
+	}
+
+	for len(fpart) <= precision {
+		fpart += "0"
+	}
+
+	digits := ipart + fpart[:precision]
+	res, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		// слишком большое значение
+		return int64(math.Round(val * math.Pow10(precision)))
+	}
+
+	if fpart[precision] >= '5' {
+		res++
+	}
+
+	if val < 0 {
+		return -res
+	}
+
+	return res
+}
+
+// ----------------------------------------------------------------------------------
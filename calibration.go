@@ -0,0 +1,173 @@
+// Калибровка (преобразование "сырых" значений по кусочно-линейной диаграмме)
+// Диаграммы задаются в секции <Calibrations> configure.xml:
+//
+//	<Calibrations name="Calibrations">
+//		<diagram name="testcal">
+//			<point x="-1000" y="-300"/>
+//			...
+//		</diagram>
+//	</Calibrations>
+//
+// x - "сырое" значение, y - калиброванное.
+// Значения за пределами диаграммы ограничиваются её крайними точками.
+// Для датчика диаграмма может быть указана в атрибуте caldiagram (см. CalibrationForSensor)
+package uniset
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"uniset/config"
+)
+
+// ----------------------------------------------------------------------------------
+type Calibration struct {
+	name   string
+	points []config.Point // упорядочены по x
+}
+
+// ----------------------------------------------------------------------------------
+// Создание калибровки по диаграмме
+func NewCalibration(d *config.Diagram) (*Calibration, error) {
+
+	if d == nil {
+		return nil, errors.New("(Calibration): diagram is nil")
+	}
+
+	if len(d.Points) < 2 {
+		return nil, fmt.Errorf("(Calibration): diagram '%s' must have at least two points", d.Name)
+	}
+
+	c := Calibration{}
+	c.name = d.Name
+	c.points = make([]config.Point, len(d.Points))
+	copy(c.points, d.Points)
+
+	sort.SliceStable(c.points, func(i, j int) bool {
+		return c.points[i].X < c.points[j].X
+	})
+
+	for i := 1; i < len(c.points); i++ {
+		if c.points[i].X == c.points[i-1].X {
+			return nil, fmt.Errorf("(Calibration): diagram '%s': duplicate point x=%v", d.Name, c.points[i].X)
+		}
+	}
+
+	return &c, nil
+}
+
+// ----------------------------------------------------------------------------------
+// Получение калибровки по имени диаграммы (из конфигурации загруженной при Init)
+func CalibrationByName(name string) (*Calibration, error) {
+
+	conf, err := requireConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	d := conf.Diagram(name)
+	if d == nil {
+		return nil, fmt.Errorf("(CalibrationByName): diagram '%s' not found", name)
+	}
+
+	return NewCalibration(d)
+}
+
+// ----------------------------------------------------------------------------------
+// Получение калибровки указанной для датчика (атрибут caldiagram)
+// Если для датчика калибровка не задана, возвращается nil (без ошибки)
+func CalibrationForSensor(sid ObjectID) (*Calibration, error) {
+
+	si, err := SensorInfoByID(sid)
+	if err != nil {
+		return nil, err
+	}
+
+	name := si.Attr("caldiagram")
+	if len(name) == 0 {
+		return nil, nil
+	}
+
+	return CalibrationByName(name)
+}
+
+// ----------------------------------------------------------------------------------
+func (c *Calibration) Name() string {
+	return c.name
+}
+
+// ----------------------------------------------------------------------------------
+// Преобразование "сырого" значения в калиброванное
+func (c *Calibration) Calibrate(raw float64) float64 {
+
+	p := c.points
+	last := len(p) - 1
+
+	if raw <= p[0].X {
+		return p[0].Y
+	}
+
+	if raw >= p[last].X {
+		return p[last].Y
+	}
+
+	// первая точка у которой x >= raw
+	i := sort.Search(len(p), func(i int) bool { return p[i].X >= raw })
+
+	return interpolate(raw, p[i-1].X, p[i-1].Y, p[i].X, p[i].Y)
+}
+
+// ----------------------------------------------------------------------------------
+// Обратное преобразование (калиброванное значение в "сырое")
+// Если диаграмма не монотонна (или есть горизонтальные участки),
+// возвращается наименьшее подходящее "сырое" значение.
+func (c *Calibration) Raw(cal float64) float64 {
+
+	p := c.points
+
+	for i := 1; i < len(p); i++ {
+
+		y1 := math.Min(p[i-1].Y, p[i].Y)
+		y2 := math.Max(p[i-1].Y, p[i].Y)
+
+		if cal < y1 || cal > y2 {
+			continue
+		}
+
+		if p[i-1].Y == p[i].Y {
+			return p[i-1].X
+		}
+
+		return interpolate(cal, p[i-1].Y, p[i-1].X, p[i].Y, p[i].X)
+	}
+
+	// за пределами диаграммы: берём ближайшую (по y) крайнюю точку
+	first := p[0]
+	last := p[len(p)-1]
+
+	if math.Abs(cal-first.Y) <= math.Abs(cal-last.Y) {
+		return first.X
+	}
+
+	return last.X
+}
+
+// ----------------------------------------------------------------------------------
+// Калибровка целочисленного значения (с округлением)
+func (c *Calibration) GetValue(raw int64) int64 {
+	return int64(math.Round(c.Calibrate(float64(raw))))
+}
+
+// ----------------------------------------------------------------------------------
+// Обратное преобразование целочисленного значения (с округлением)
+func (c *Calibration) GetRawValue(cal int64) int64 {
+	return int64(math.Round(c.Raw(float64(cal))))
+}
+
+// ----------------------------------------------------------------------------------
+func interpolate(x, x1, y1, x2, y2 float64) float64 {
+	return y1 + (x-x1)*(y2-y1)/(x2-x1)
+}
+
+// ----------------------------------------------------------------------------------
@@ -0,0 +1,325 @@
+// Генерация go-кода
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"strings"
+	"text/template"
+)
+
+// ----------------------------------------------------------------------------------
+// типы переменных: тип src.xml --> go-тип и функция чтения настройки
+var varTypes = map[string]struct {
+	GoType string
+	Read   string // метод uniset.ConfigReader
+}{
+	"int":    {"int32", "Int32"},
+	"long":   {"int64", "Int64"},
+	"float":  {"float32", "Float32"},
+	"double": {"float64", "Float64"},
+	"bool":   {"bool", "Bool"},
+	"str":    {"string", "String"},
+	"sensor": {"uniset.ObjectID", "SensorID"},
+	"object": {"uniset.ObjectID", "ObjectID"},
+}
+
+// ----------------------------------------------------------------------------------
+var funcs = template.FuncMap{
+	"gotype": func(v *Variable) string {
+		return varTypes[v.Type].GoType
+	},
+	"readfunc": func(v *Variable) string {
+		return varTypes[v.Type].Read
+	},
+	"valtype": func(s *Sensor) string {
+		return sensorValueType(s)
+	},
+	"newvalue": func(s *Sensor, prefix string) string {
+		switch sensorValueType(s) {
+		case "bool":
+			return fmt.Sprintf("uniset.NewBoolValue(&s.%s, &s.%s%s)", s.Name, prefix, s.Name)
+		case "float64":
+			return fmt.Sprintf("uniset.NewFloat64Value(&s.%s, &s.%s%s, %d)", s.Name, prefix, s.Name, s.Precision)
+		}
+		return fmt.Sprintf("uniset.NewInt64Value(&s.%s, &s.%s%s)", s.Name, prefix, s.Name)
+	},
+	"quote": func(s string) string {
+		return fmt.Sprintf("%q", s)
+	},
+	"comment": func(s string) string {
+		if len(s) == 0 {
+			return ""
+		}
+		return "// " + strings.ReplaceAll(s, "\n", " ")
+	},
+}
+
+// ----------------------------------------------------------------------------------
+func sensorValueType(s *Sensor) string {
+
+	if s.IOType == "DI" || s.IOType == "DO" {
+		return "bool"
+	}
+
+	if s.Precision >= 0 {
+		return "float64"
+	}
+
+	return "int64"
+}
+
+// ----------------------------------------------------------------------------------
+func Generate(src *Source, pkg string) ([]byte, error) {
+
+	if !token.IsIdentifier(src.ClassName) {
+		return nil, fmt.Errorf("bad class name '%s'", src.ClassName)
+	}
+
+	if !token.IsIdentifier(pkg) {
+		return nil, fmt.Errorf("bad package name '%s'", pkg)
+	}
+
+	data := struct {
+		*Source
+		Package string
+	}{src, pkg}
+
+	var buf bytes.Buffer
+	if err := codeTemplate.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+
+	code, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("format generated code error: %s", err)
+	}
+
+	return code, nil
+}
+
+// ----------------------------------------------------------------------------------
+var codeTemplate = template.Must(template.New("code").Funcs(funcs).Parse(`// Code generated by uniset-codegen-go. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"time"
+	"uniset"
+)
+
+// ----------------------------------------------------------------------------------
+// Пользовательская логика для {{.ClassName}}
+type {{.ClassName}}_Logic interface {
+
+	// вызывается периодически (sleep_msec)
+	Step()
+
+	// вызывается при изменении любого из входов (после обновления полей)
+	SensorInfo(sm *uniset.SensorEvent)
+}
+
+// ----------------------------------------------------------------------------------
+type {{.ClassName}}_SK struct {
+
+	// идентификаторы датчиков
+{{- range .Inputs}}
+	{{.Name}} uniset.ObjectID {{comment .Comment}}
+{{- end}}
+{{- range .Outputs}}
+	{{.Name}} uniset.ObjectID {{comment .Comment}}
+{{- end}}
+{{- range .Messages}}
+	{{.Name}} uniset.ObjectID {{comment .Comment}}
+{{- end}}
+
+	// входы
+{{- range .Inputs}}
+	in_{{.Name}} {{valtype .}}
+{{- end}}
+
+	// выходы
+{{- range .Outputs}}
+	out_{{.Name}} {{valtype .}}
+{{- end}}
+
+	// сообщения
+{{- range .Messages}}
+	m_{{.Name}} bool
+{{- end}}
+
+	// переменные
+{{- range .Variables}}
+	{{.Name}} {{gotype .}} {{comment .Comment}}
+{{- end}}
+
+	myname     string
+	id         uniset.ObjectID
+	sleep_msec int32
+
+	ins  []uniset.Binding
+	outs []uniset.Binding
+
+	evchannel  chan uniset.UMessage
+	cmdchannel chan uniset.UMessage
+}
+
+// ----------------------------------------------------------------------------------
+// Инициализация (настройки берутся из секции <section><xxx name="name" .../></section>)
+// Возвращает сразу все найденные ошибки конфигурирования (см. uniset.ConfigReader)
+// При --help или --name-help выводит список свойств и возвращает uniset.ErrHelp (см. uniset.HandlePropArgs)
+func Init_{{.ClassName}}(s *{{.ClassName}}_SK, name string, section string) error {
+
+	cfg, err := uniset.GetConfigParamsByName(name, section)
+	if err != nil {
+		return err
+	}
+
+	r := uniset.NewConfigReader(cfg)
+	r.Help("sleep_msec", "период основного цикла, мсек")
+{{- range .Inputs}}{{if .Comment}}
+	r.Help("{{.Name}}", {{quote .Comment}})
+{{- end}}{{end}}
+{{- range .Outputs}}{{if .Comment}}
+	r.Help("{{.Name}}", {{quote .Comment}})
+{{- end}}{{end}}
+{{- range .Messages}}{{if .Comment}}
+	r.Help("{{.Name}}", {{quote .Comment}})
+{{- end}}{{end}}
+{{- range .Variables}}{{if .Comment}}
+	r.Help("{{.Name}}", {{quote .Comment}})
+{{- end}}{{end}}
+
+	s.myname = name
+	s.id = r.ObjectID("name", name)
+	s.sleep_msec = r.Int32("sleep_msec", "{{.SleepMsec}}")
+{{if or .Inputs .Outputs}}
+	// датчики из smap обязательны: без них объект работать не может
+	r.Require(
+{{- range .Inputs}}
+		"{{.Name}}",
+{{- end}}
+{{- range .Outputs}}
+		"{{.Name}}",
+{{- end}}
+	)
+{{end}}
+{{- range .Inputs}}
+	s.{{.Name}} = r.SensorID("{{.Name}}", "")
+{{- end}}
+{{- range .Outputs}}
+	s.{{.Name}} = r.SensorID("{{.Name}}", "")
+{{- end}}
+{{- range .Messages}}
+	s.{{.Name}} = r.SensorID("{{.Name}}", "")
+{{- end}}
+{{range .Variables}}
+	s.{{.Name}} = r.{{readfunc .}}("{{.Name}}", {{quote .Default}})
+{{- end}}
+
+	// --help, --name-help (uniset.ErrHelp) и неизвестные аргументы --name-xxx
+	// справка выводится и при ошибках конфигурирования
+	if err := uniset.HandlePropArgs(cfg); err != nil {
+		return err
+	}
+
+	if err := r.Err(); err != nil {
+		return err
+	}
+
+	s.ins = []uniset.Binding{
+{{- range .Inputs}}
+		{{newvalue . "in_"}},
+{{- end}}
+	}
+
+	s.outs = []uniset.Binding{
+{{- range .Outputs}}
+		{{newvalue . "out_"}},
+{{- end}}
+{{- range .Messages}}
+		uniset.NewBoolValue(&s.{{.Name}}, &s.m_{{.Name}}),
+{{- end}}
+	}
+
+	s.evchannel = make(chan uniset.UMessage, 10+len(s.ins))
+	s.cmdchannel = make(chan uniset.UMessage, 10+len(s.ins)+len(s.outs))
+
+	return nil
+}
+
+// ----------------------------------------------------------------------------------
+// реализация интерфейса uniset.UObject
+func (s *{{.ClassName}}_SK) ID() uniset.ObjectID {
+	return s.id
+}
+
+func (s *{{.ClassName}}_SK) UEvent() chan<- uniset.UMessage {
+	return s.evchannel
+}
+
+func (s *{{.ClassName}}_SK) UCommand() <-chan uniset.UMessage {
+	return s.cmdchannel
+}
+
+// ----------------------------------------------------------------------------------
+func (s *{{.ClassName}}_SK) Name() string {
+	return s.myname
+}
+
+// ----------------------------------------------------------------------------------
+// Основной цикл обработки (до получения FinishEvent)
+// ui - UProxy, к которому добавлен объект (через него читаются начальные значения входов)
+func (s *{{.ClassName}}_SK) Run(logic {{.ClassName}}_Logic, ui *uniset.UProxy) {
+
+	period := time.Duration(s.sleep_msec) * time.Millisecond
+	if period <= 0 {
+		period = 100 * time.Millisecond
+	}
+
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	active := false
+
+	for {
+		select {
+		case umsg, ok := <-s.evchannel:
+
+			if !ok {
+				return
+			}
+
+			if _, ok := umsg.PopAsActivateEvent(); ok {
+				uniset.DoReadInputsFrom(ui, &s.ins)
+				uniset.DoAskSensors(&s.ins, s.cmdchannel)
+				active = true
+				continue
+			}
+
+			if sm, ok := umsg.PopAsSensorEvent(); ok {
+				uniset.DoUpdateInputs(&s.ins, sm)
+				logic.SensorInfo(sm)
+				continue
+			}
+
+			if _, ok := umsg.PopAsFinishEvent(); ok {
+				return
+			}
+
+		case <-ticker.C:
+
+			if !active {
+				continue
+			}
+
+			logic.Step()
+			uniset.DoUpdateOutputs(&s.outs, s.cmdchannel)
+		}
+	}
+}
+
+// ----------------------------------------------------------------------------------
+`))
@@ -0,0 +1,71 @@
+// uniset-codegen-go - генератор go-кода для uniset-объектов по xml-описанию (src.xml)
+// Формат src.xml аналогичен используемому uniset2-codegen (settings, variables, smap, msgmap).
+// Генерируется базовая структура <Class>_SK, которую достаточно встроить в свой объект
+// (как анонимное поле), функция инициализации Init_<Class> и цикл обработки событий Run.
+// ---------
+// Использование (в том числе через go generate):
+//
+//	//go:generate uniset-codegen-go -p main -o testproc_sk.go src.xml
+//
+// ---------
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+// ----------------------------------------------------------------------------------
+func main() {
+
+	pkg := flag.String("p", "", "имя go-пакета (по умолчанию $GOPACKAGE или main)")
+	out := flag.String("o", "", "имя выходного файла (по умолчанию <class>_sk.go)")
+	name := flag.String("n", "", "имя класса (по умолчанию из settings/class-name)")
+
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [options] src.xml\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if len(*pkg) == 0 {
+		*pkg = os.Getenv("GOPACKAGE")
+		if len(*pkg) == 0 {
+			*pkg = "main"
+		}
+	}
+
+	src, err := LoadSource(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "(uniset-codegen-go): %s\n", err)
+		os.Exit(1)
+	}
+
+	if len(*name) > 0 {
+		src.ClassName = *name
+	}
+
+	code, err := Generate(src, *pkg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "(uniset-codegen-go): %s\n", err)
+		os.Exit(1)
+	}
+
+	if len(*out) == 0 {
+		*out = fmt.Sprintf("%s_sk.go", src.ClassName)
+	}
+
+	if err = os.WriteFile(*out, code, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "(uniset-codegen-go): %s\n", err)
+		os.Exit(1)
+	}
+}
+
+// ----------------------------------------------------------------------------------
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+)
+
+func TestLoadSource(t *testing.T) {
+
+	src, err := LoadSource("testdata/src.xml")
+	if err != nil {
+		t.Fatalf("LoadSource error: %s", err)
+	}
+
+	if src.ClassName != "TestProc" {
+		t.Errorf("class name: %s != TestProc", src.ClassName)
+	}
+
+	if src.SleepMsec != 150 {
+		t.Errorf("sleep-msec: %d != 150", src.SleepMsec)
+	}
+
+	if len(src.Inputs) != 3 || len(src.Outputs) != 2 || len(src.Messages) != 1 {
+		t.Errorf("smap: wrong in/out/msg count %d/%d/%d", len(src.Inputs), len(src.Outputs), len(src.Messages))
+	}
+
+	if len(src.Variables) != 8 {
+		t.Errorf("variables: %d != 8", len(src.Variables))
+	}
+
+	_, err = ParseSource([]byte(`<Bad><smap><item name="x" vartype="in" iotype="XX"/></smap></Bad>`))
+	if err == nil {
+		t.Error("unknown iotype: must be error")
+	}
+
+	_, err = ParseSource([]byte(`<Bad><variables><item name="bad-name" type="int"/></variables></Bad>`))
+	if err == nil {
+		t.Error("bad variable name: must be error")
+	}
+}
+
+func TestGenerate(t *testing.T) {
+
+	src, err := LoadSource("testdata/src.xml")
+	if err != nil {
+		t.Fatalf("LoadSource error: %s", err)
+	}
+
+	code, err := Generate(src, "main")
+	if err != nil {
+		t.Fatalf("Generate error: %s", err)
+	}
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "gen_sk.go", code, 0)
+	if err != nil {
+		t.Fatalf("generated code parse error: %s", err)
+	}
+
+	// проверка типов сгенерированного кода по исходникам пакета uniset
+	// (purego - чтобы не зависеть от c++ uniset_internal_api)
+	tags := build.Default.BuildTags
+	build.Default.BuildTags = append(tags, "purego")
+	defer func() { build.Default.BuildTags = tags }()
+
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	if _, err := conf.Check("main", fset, []*ast.File{f}, nil); err != nil {
+		t.Fatalf("generated code type error: %s", err)
+	}
+
+	s := string(code)
+
+	expected := []string{
+		"type TestProc_SK struct",
+		"func Init_TestProc(s *TestProc_SK, name string, section string) error",
+		"in_input1_s bool",
+		"in_input2_s int64",
+		"in_level_s  float64",
+		"out_output2_c int64",
+		"m_mid_Alarm bool",
+		"uniset.NewFloat64Value(&s.level_s, &s.in_level_s, 2)",
+		`s.test_int = r.Int32("test_int", "10")`,
+		`s.sleep_msec = r.Int32("sleep_msec", "150")`,
+		`s.input1_s = r.SensorID("input1_s", "")`,
+		"r.Require(\n\t\t\"input1_s\",",
+		"uniset.DoReadInputsFrom(ui, &s.ins)",
+		"\t\t\"output2_c\",\n\t)",
+	}
+
+	for _, e := range expected {
+		if !strings.Contains(s, e) {
+			t.Errorf("generated code: not found '%s'", e)
+		}
+	}
+
+	if _, err := Generate(src, "bad-pkg"); err == nil {
+		t.Error("bad package name: must be error")
+	}
+}
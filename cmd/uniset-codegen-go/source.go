@@ -0,0 +1,179 @@
+// Разбор xml-описания объекта (src.xml)
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"go/token"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ----------------------------------------------------------------------------------
+type Source struct {
+	ClassName string
+	SleepMsec int
+	Variables []*Variable
+	Inputs    []*Sensor
+	Outputs   []*Sensor
+	Messages  []*Message
+}
+
+// ----------------------------------------------------------------------------------
+type Variable struct {
+	Name    string
+	Type    string // тип из src.xml
+	Default string
+	Comment string
+}
+
+// ----------------------------------------------------------------------------------
+type Sensor struct {
+	Name      string
+	IOType    string
+	Precision int // -1 - не задана
+	Comment   string
+}
+
+// ----------------------------------------------------------------------------------
+type Message struct {
+	Name    string
+	Comment string
+}
+
+// ----------------------------------------------------------------------------------
+type xmlItem struct {
+	Name      string `xml:"name,attr"`
+	Type      string `xml:"type,attr"`
+	Default   string `xml:"default,attr"`
+	Comment   string `xml:"comment,attr"`
+	VarType   string `xml:"vartype,attr"`
+	IOType    string `xml:"iotype,attr"`
+	Precision string `xml:"precision,attr"`
+}
+
+type xmlSource struct {
+	XMLName  xml.Name
+	Settings []struct {
+		Name string `xml:"name,attr"`
+		Val  string `xml:"val,attr"`
+	} `xml:"settings>set"`
+	Variables []xmlItem `xml:"variables>item"`
+	Smap      []xmlItem `xml:"smap>item"`
+	Msgmap    []xmlItem `xml:"msgmap>item"`
+}
+
+// ----------------------------------------------------------------------------------
+func LoadSource(filename string) (*Source, error) {
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	src, err := ParseSource(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err)
+	}
+
+	return src, nil
+}
+
+// ----------------------------------------------------------------------------------
+func ParseSource(data []byte) (*Source, error) {
+
+	var xs xmlSource
+	if err := xml.Unmarshal(data, &xs); err != nil {
+		return nil, err
+	}
+
+	src := Source{}
+	names := make(map[string]bool)
+
+	checkName := func(name string) error {
+		if !token.IsIdentifier(name) {
+			return fmt.Errorf("bad name '%s' (must be go identifier)", name)
+		}
+		if names[name] {
+			return fmt.Errorf("duplicate name '%s'", name)
+		}
+		names[name] = true
+		return nil
+	}
+
+	for _, s := range xs.Settings {
+		switch s.Name {
+		case "class-name":
+			src.ClassName = s.Val
+		case "sleep-msec":
+			v, err := strconv.Atoi(s.Val)
+			if err != nil || v < 0 {
+				return nil, fmt.Errorf("bad sleep-msec='%s'", s.Val)
+			}
+			src.SleepMsec = v
+		}
+	}
+
+	if len(src.ClassName) == 0 {
+		src.ClassName = xs.XMLName.Local
+	}
+
+	for _, it := range xs.Variables {
+
+		if err := checkName(it.Name); err != nil {
+			return nil, err
+		}
+
+		if _, found := varTypes[it.Type]; !found {
+			return nil, fmt.Errorf("variable '%s': unknown type '%s'", it.Name, it.Type)
+		}
+
+		src.Variables = append(src.Variables, &Variable{it.Name, it.Type, it.Default, it.Comment})
+	}
+
+	for _, it := range xs.Smap {
+
+		if err := checkName(it.Name); err != nil {
+			return nil, err
+		}
+
+		s := Sensor{Name: it.Name, IOType: strings.ToUpper(it.IOType), Precision: -1, Comment: it.Comment}
+
+		switch s.IOType {
+		case "DI", "DO", "AI", "AO":
+		default:
+			return nil, fmt.Errorf("sensor '%s': unknown iotype '%s'", it.Name, it.IOType)
+		}
+
+		if len(it.Precision) > 0 {
+			p, err := strconv.Atoi(it.Precision)
+			if err != nil || p < 0 {
+				return nil, fmt.Errorf("sensor '%s': bad precision '%s'", it.Name, it.Precision)
+			}
+			s.Precision = p
+		}
+
+		switch it.VarType {
+		case "in":
+			src.Inputs = append(src.Inputs, &s)
+		case "out":
+			src.Outputs = append(src.Outputs, &s)
+		default:
+			return nil, fmt.Errorf("sensor '%s': unknown vartype '%s' (must be 'in' or 'out')", it.Name, it.VarType)
+		}
+	}
+
+	for _, it := range xs.Msgmap {
+
+		if err := checkName(it.Name); err != nil {
+			return nil, err
+		}
+
+		src.Messages = append(src.Messages, &Message{it.Name, it.Comment})
+	}
+
+	return &src, nil
+}
+
+// ----------------------------------------------------------------------------------
@@ -0,0 +1,513 @@
+// Разбор файла конфигурации uniset (configure.xml) без использования c++-части.
+// Поддерживаются:
+//   - <UniSet>       - общие настройки
+//   - <ObjectsMap>   - карта объектов (nodes, sensors, controllers, services, objects)
+//   - <messages>     - список сообщений
+//   - <thresholds>   - пороги
+//   - <Calibrations> - калибровочные диаграммы
+//
+// Поддерживается только режим idfromfile="1" (идентификаторы берутся из файла).
+// ---------
+// Пример:
+//
+//	conf, err := config.Load("configure.xml")
+//	sid := conf.SensorID("Input1_S")
+//
+// ---------
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// ----------------------------------------------------------------------------------
+// идентификатор возвращаемый, если объект не найден (аналог uniset.DefaultObjectID)
+const DefaultObjectID int64 = -1
+
+// ----------------------------------------------------------------------------------
+// Названия секций ObjectsMap
+const (
+	SectionNodes       = "nodes"
+	SectionSensors     = "sensors"
+	SectionControllers = "controllers"
+	SectionServices    = "services"
+	SectionObjects     = "objects"
+	SectionMessages    = "messages"
+)
+
+// ----------------------------------------------------------------------------------
+type Config struct {
+	Settings     Settings
+	Nodes        []*ObjectInfo
+	Sensors      []*ObjectInfo
+	Controllers  []*ObjectInfo
+	Services     []*ObjectInfo
+	Objects      []*ObjectInfo
+	Messages     []*ObjectInfo
+	Thresholds   []*Threshold
+	Calibrations []*Diagram
+
+	Root *Node // всё xml-дерево
+
+	byID   map[int64]*ObjectInfo
+	byName map[string]*ObjectInfo
+	diags  map[string]*Diagram
+}
+
+// ----------------------------------------------------------------------------------
+// Секция <UniSet>
+type Settings struct {
+	NameServiceHost string
+	NameServicePort int
+	LocalNode       string
+	RootSection     string
+
+	// прочие параметры вида <Param name="value"/>
+	Params map[string]string
+
+	Node *Node
+}
+
+// ----------------------------------------------------------------------------------
+// Элемент ObjectsMap (или messages)
+type ObjectInfo struct {
+	ID       int64
+	Name     string
+	TextName string
+	Section  string            // секция в которой описан объект (sensors, objects, ...)
+	Attrs    map[string]string // все атрибуты (в том числе id, name, textname)
+}
+
+// ----------------------------------------------------------------------------------
+// Порог из секции <thresholds>
+//
+//	<sensor name="AI_S">
+//		<threshold name="t1" id="1" lowlimit="30" hilimit="40" sid="Threshold_S" inverse="0"/>
+//	</sensor>
+type Threshold struct {
+	ID       int64
+	Name     string
+	Sensor   string // аналоговый датчик для которого задан порог
+	SID      string // связанный дискретный датчик
+	LowLimit int64
+	HiLimit  int64
+	Inverse  bool
+	Attrs    map[string]string
+}
+
+// ----------------------------------------------------------------------------------
+// Калибровочная диаграмма из секции <Calibrations>
+type Diagram struct {
+	Name   string
+	Points []Point
+}
+
+// ----------------------------------------------------------------------------------
+type Point struct {
+	X float64
+	Y float64
+}
+
+// ----------------------------------------------------------------------------------
+// Загрузить конфигурацию из файла
+func Load(confile string) (*Config, error) {
+
+	data, err := os.ReadFile(confile)
+	if err != nil {
+		return nil, fmt.Errorf("(config): read '%s' error: %s", confile, err)
+	}
+
+	conf, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("(config): '%s': %s", confile, err)
+	}
+
+	return conf, nil
+}
+
+// ----------------------------------------------------------------------------------
+// Разобрать конфигурацию
+func Parse(data []byte) (*Config, error) {
+
+	root, err := parseNodes(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if root == nil {
+		return nil, errors.New("empty configuration")
+	}
+
+	conf := Config{}
+	conf.Root = root
+	conf.byID = make(map[int64]*ObjectInfo)
+	conf.byName = make(map[string]*ObjectInfo)
+	conf.diags = make(map[string]*Diagram)
+
+	conf.Settings = parseSettings(root.Child("UniSet"))
+
+	if omap := root.Child("ObjectsMap"); omap != nil {
+
+		sections := []struct {
+			tag  string
+			list *[]*ObjectInfo
+		}{
+			{SectionNodes, &conf.Nodes},
+			{SectionSensors, &conf.Sensors},
+			{SectionControllers, &conf.Controllers},
+			{SectionServices, &conf.Services},
+			{SectionObjects, &conf.Objects},
+		}
+
+		for _, s := range sections {
+			if *s.list, err = conf.parseItems(omap.Child(s.tag), s.tag); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// сообщения имеют собственное пространство идентификаторов
+	// поэтому в общие таблицы поиска не попадают
+	if m := root.Child("messages"); m != nil {
+		for _, it := range m.Children {
+			info, err := makeObjectInfo(it, SectionMessages)
+			if err != nil {
+				return nil, err
+			}
+			conf.Messages = append(conf.Messages, info)
+		}
+	}
+
+	// секция порогов может находиться как в корне, так и в ObjectsMap
+	th := root.Child("thresholds")
+	if th == nil && root.Child("ObjectsMap") != nil {
+		th = root.Child("ObjectsMap").Child("thresholds")
+	}
+
+	if conf.Thresholds, err = parseThresholds(th); err != nil {
+		return nil, err
+	}
+
+	if conf.Calibrations, err = parseCalibrations(root.Child("Calibrations")); err != nil {
+		return nil, err
+	}
+
+	for _, d := range conf.Calibrations {
+		conf.diags[d.Name] = d
+	}
+
+	return &conf, nil
+}
+
+// ----------------------------------------------------------------------------------
+// Поиск объекта (в любой секции ObjectsMap) по имени
+func (c *Config) ObjectInfoByName(name string) *ObjectInfo {
+	return c.byName[name]
+}
+
+// ----------------------------------------------------------------------------------
+// Поиск объекта (в любой секции ObjectsMap) по идентификатору
+func (c *Config) ObjectInfoByID(id int64) *ObjectInfo {
+	return c.byID[id]
+}
+
+// ----------------------------------------------------------------------------------
+// Идентификатор датчика по имени (DefaultObjectID если не найден)
+func (c *Config) SensorID(name string) int64 {
+	return c.idFromSection(name, SectionSensors)
+}
+
+// ----------------------------------------------------------------------------------
+// Идентификатор объекта по имени (DefaultObjectID если не найден)
+func (c *Config) ObjectID(name string) int64 {
+	return c.idFromSection(name, SectionObjects)
+}
+
+// ----------------------------------------------------------------------------------
+// Идентификатор узла по имени (DefaultObjectID если не найден)
+func (c *Config) NodeID(name string) int64 {
+	return c.idFromSection(name, SectionNodes)
+}
+
+// ----------------------------------------------------------------------------------
+// Идентификатор (любого объекта ObjectsMap) по имени (DefaultObjectID если не найден)
+func (c *Config) ID(name string) int64 {
+
+	if o, found := c.byName[name]; found {
+		return o.ID
+	}
+
+	return DefaultObjectID
+}
+
+// ----------------------------------------------------------------------------------
+// Имя по идентификатору (пустая строка если не найден)
+func (c *Config) NameByID(id int64) string {
+
+	if o, found := c.byID[id]; found {
+		return o.Name
+	}
+
+	return ""
+}
+
+// ----------------------------------------------------------------------------------
+// Поиск сообщения по имени
+func (c *Config) MessageByName(name string) *ObjectInfo {
+
+	for _, m := range c.Messages {
+		if m.Name == name {
+			return m
+		}
+	}
+
+	return nil
+}
+
+// ----------------------------------------------------------------------------------
+// Поиск калибровочной диаграммы по имени
+func (c *Config) Diagram(name string) *Diagram {
+	return c.diags[name]
+}
+
+// ----------------------------------------------------------------------------------
+// Пороги заданные для указанного датчика
+func (c *Config) ThresholdsFor(sensor string) []*Threshold {
+
+	var ret []*Threshold
+	for _, t := range c.Thresholds {
+		if t.Sensor == sensor {
+			ret = append(ret, t)
+		}
+	}
+
+	return ret
+}
+
+// ----------------------------------------------------------------------------------
+// Поиск узла с настройками объекта: <section> ... <xxx name="name" .../> ... </section>
+// (аналог GetConfigParamsByName в c++-части)
+// Если section пустая, поиск идёт по всему файлу
+func (c *Config) FindNode(name string, section string) *Node {
+
+	sec := c.Root
+	if len(section) > 0 {
+		if sec = c.Root.Find(section); sec == nil {
+			return nil
+		}
+	}
+
+	return sec.FindByName(name)
+}
+
+// ----------------------------------------------------------------------------------
+func (c *Config) idFromSection(name string, section string) int64 {
+
+	if o, found := c.byName[name]; found && o.Section == section {
+		return o.ID
+	}
+
+	return DefaultObjectID
+}
+
+// ----------------------------------------------------------------------------------
+func (c *Config) parseItems(sec *Node, section string) ([]*ObjectInfo, error) {
+
+	if sec == nil {
+		return nil, nil
+	}
+
+	var list []*ObjectInfo
+
+	for _, it := range sec.Children {
+
+		info, err := makeObjectInfo(it, section)
+		if err != nil {
+			return nil, err
+		}
+
+		if prev, found := c.byID[info.ID]; found {
+			return nil, fmt.Errorf("<%s>: duplicate id=%d for '%s' and '%s'", section, info.ID, prev.Name, info.Name)
+		}
+
+		if prev, found := c.byName[info.Name]; found {
+			return nil, fmt.Errorf("<%s>: duplicate name '%s' (see <%s>)", section, info.Name, prev.Section)
+		}
+
+		c.byID[info.ID] = info
+		c.byName[info.Name] = info
+		list = append(list, info)
+	}
+
+	return list, nil
+}
+
+// ----------------------------------------------------------------------------------
+func makeObjectInfo(n *Node, section string) (*ObjectInfo, error) {
+
+	info := ObjectInfo{}
+	info.Section = section
+	info.Name = n.Attr("name")
+	info.TextName = n.Attr("textname")
+	info.Attrs = attrMap(n)
+
+	if len(info.Name) == 0 {
+		return nil, fmt.Errorf("<%s>: item without name", section)
+	}
+
+	id, err := strconv.ParseInt(n.Attr("id"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("<%s>: bad id='%s' for '%s'", section, n.Attr("id"), info.Name)
+	}
+
+	info.ID = id
+	return &info, nil
+}
+
+// ----------------------------------------------------------------------------------
+func parseSettings(n *Node) Settings {
+
+	s := Settings{}
+	s.Params = make(map[string]string)
+	s.Node = n
+
+	if n == nil {
+		return s
+	}
+
+	for _, c := range n.Children {
+
+		if v, ok := c.LookupAttr("name"); ok {
+			s.Params[c.Name] = v
+		}
+
+		switch c.Name {
+		case "NameService":
+			s.NameServiceHost = c.Attr("host")
+			s.NameServicePort, _ = strconv.Atoi(c.Attr("port"))
+		case "LocalNode":
+			s.LocalNode = c.Attr("name")
+		case "RootSection":
+			s.RootSection = c.Attr("name")
+		}
+	}
+
+	return s
+}
+
+// ----------------------------------------------------------------------------------
+func parseThresholds(n *Node) ([]*Threshold, error) {
+
+	if n == nil {
+		return nil, nil
+	}
+
+	var list []*Threshold
+
+	for _, s := range n.Children {
+
+		sensor := s.Attr("name")
+
+		for _, t := range s.Children {
+
+			th := Threshold{}
+			th.Sensor = sensor
+			th.Name = t.Attr("name")
+			th.SID = t.Attr("sid")
+			th.Attrs = attrMap(t)
+
+			var err error
+			if th.ID, err = parseInt(t, "id", 0); err != nil {
+				return nil, fmt.Errorf("<thresholds>: sensor '%s': %s", sensor, err)
+			}
+
+			if th.LowLimit, err = parseInt(t, "lowlimit", 0); err != nil {
+				return nil, fmt.Errorf("<thresholds>: sensor '%s': %s", sensor, err)
+			}
+
+			if th.HiLimit, err = parseInt(t, "hilimit", 0); err != nil {
+				return nil, fmt.Errorf("<thresholds>: sensor '%s': %s", sensor, err)
+			}
+
+			inv, err := parseInt(t, "inverse", 0)
+			if err != nil {
+				return nil, fmt.Errorf("<thresholds>: sensor '%s': %s", sensor, err)
+			}
+
+			th.Inverse = (inv != 0)
+			list = append(list, &th)
+		}
+	}
+
+	return list, nil
+}
+
+// ----------------------------------------------------------------------------------
+func parseCalibrations(n *Node) ([]*Diagram, error) {
+
+	if n == nil {
+		return nil, nil
+	}
+
+	var list []*Diagram
+
+	for _, d := range n.Children {
+
+		if d.Name != "diagram" {
+			continue
+		}
+
+		diag := Diagram{Name: d.Attr("name")}
+
+		for _, p := range d.Children {
+
+			x, err := strconv.ParseFloat(p.Attr("x"), 64)
+			if err != nil {
+				return nil, fmt.Errorf("<Calibrations>: diagram '%s': bad x='%s'", diag.Name, p.Attr("x"))
+			}
+
+			y, err := strconv.ParseFloat(p.Attr("y"), 64)
+			if err != nil {
+				return nil, fmt.Errorf("<Calibrations>: diagram '%s': bad y='%s'", diag.Name, p.Attr("y"))
+			}
+
+			diag.Points = append(diag.Points, Point{x, y})
+		}
+
+		list = append(list, &diag)
+	}
+
+	return list, nil
+}
+
+// ----------------------------------------------------------------------------------
+func parseInt(n *Node, attr string, defval int64) (int64, error) {
+
+	v := n.Attr(attr)
+	if len(v) == 0 {
+		return defval, nil
+	}
+
+	i, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("bad %s='%s'", attr, v)
+	}
+
+	return i, nil
+}
+
+// ----------------------------------------------------------------------------------
+func attrMap(n *Node) map[string]string {
+
+	m := make(map[string]string, len(n.Attrs))
+	for _, a := range n.Attrs {
+		m[a.Name] = a.Value
+	}
+
+	return m
+}
+
+// ----------------------------------------------------------------------------------
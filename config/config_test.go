@@ -0,0 +1,134 @@
+package config_test
+
+import (
+	"testing"
+	"uniset/config"
+)
+
+// ----------------------------------------------------------------
+func loadTestConfig(t *testing.T) *config.Config {
+
+	conf, err := config.Load("../configure.xml")
+	if err != nil {
+		t.Fatalf("Load: error: %s", err)
+	}
+
+	return conf
+}
+
+// ----------------------------------------------------------------
+func TestSettings(t *testing.T) {
+
+	conf := loadTestConfig(t)
+
+	if conf.Settings.NameServicePort != 2809 {
+		t.Errorf("Settings: NameService port=%d != 2809", conf.Settings.NameServicePort)
+	}
+
+	if conf.Settings.LocalNode != "localhost" {
+		t.Errorf("Settings: LocalNode='%s' != 'localhost'", conf.Settings.LocalNode)
+	}
+
+	if conf.Settings.Params["SleepTickMS"] != "500" {
+		t.Errorf("Settings: SleepTickMS='%s' != '500'", conf.Settings.Params["SleepTickMS"])
+	}
+}
+
+// ----------------------------------------------------------------
+func TestObjectsMap(t *testing.T) {
+
+	conf := loadTestConfig(t)
+
+	if id := conf.SensorID("AI20_S"); id != 20 {
+		t.Errorf("SensorID('AI20_S')=%d != 20", id)
+	}
+
+	if id := conf.SensorID("TestProc"); id != config.DefaultObjectID {
+		t.Errorf("SensorID('TestProc')=%d for object", id)
+	}
+
+	if id := conf.ObjectID("TestProc"); id != 100 {
+		t.Errorf("ObjectID('TestProc')=%d != 100", id)
+	}
+
+	if id := conf.NodeID("node2"); id != 1001 {
+		t.Errorf("NodeID('node2')=%d != 1001", id)
+	}
+
+	if id := conf.ID("SharedMemory1"); id != 90 {
+		t.Errorf("ID('SharedMemory1')=%d != 90", id)
+	}
+
+	if name := conf.NameByID(99); name != "TimeService" {
+		t.Errorf("NameByID(99)='%s' != 'TimeService'", name)
+	}
+
+	info := conf.ObjectInfoByID(1)
+	if info == nil || info.Section != config.SectionSensors || info.Attrs["iotype"] != "DI" {
+		t.Errorf("ObjectInfoByID(1): bad info %v", info)
+	}
+
+	if m := conf.MessageByName("TestMessage1"); m == nil || m.ID != 1 {
+		t.Errorf("MessageByName('TestMessage1'): bad info %v", m)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestThresholdsAndCalibrations(t *testing.T) {
+
+	conf := loadTestConfig(t)
+
+	th := conf.ThresholdsFor("AI20_S")
+	if len(th) != 1 {
+		t.Fatalf("ThresholdsFor('AI20_S'): count=%d != 1", len(th))
+	}
+
+	if th[0].LowLimit != 30 || th[0].HiLimit != 40 || th[0].SID != "Threshold1_S" || th[0].Inverse {
+		t.Errorf("Threshold: bad values %v", th[0])
+	}
+
+	d := conf.Diagram("testcal")
+	if d == nil {
+		t.Fatal("Diagram('testcal') not found")
+	}
+
+	if len(d.Points) != 23 || d.Points[0].X != -1000 || d.Points[0].Y != -300 {
+		t.Errorf("Diagram('testcal'): bad points %v", d.Points)
+	}
+}
+
+// ----------------------------------------------------------------
+func TestFindNode(t *testing.T) {
+
+	conf := loadTestConfig(t)
+
+	n := conf.FindNode("TestProc", "settings")
+	if n == nil {
+		t.Fatal("FindNode('TestProc','settings'): not found")
+	}
+
+	if n.Attr("sleep_msec") != "150" {
+		t.Errorf("FindNode: sleep_msec='%s' != '150'", n.Attr("sleep_msec"))
+	}
+
+	if conf.FindNode("TestProc", "unknown_section") != nil {
+		t.Error("FindNode: found in unknown section")
+	}
+}
+
+// ----------------------------------------------------------------
+func TestParseErrors(t *testing.T) {
+
+	bad := []string{
+		`<Root><ObjectsMap><sensors><item id="x" name="S1"/></sensors></ObjectsMap></Root>`,
+		`<Root><ObjectsMap><sensors><item id="1" name="S1"/><item id="1" name="S2"/></sensors></ObjectsMap></Root>`,
+		`<Root><Calibrations><diagram name="d"><point x="a" y="1"/></diagram></Calibrations></Root>`,
+		`<Root><ObjectsMap>`,
+	}
+
+	for _, b := range bad {
+		if _, err := config.Parse([]byte(b)); err == nil {
+			t.Errorf("Parse: no error for %s", b)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+// Упрощённое представление xml-дерева configure.xml
+package config
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+)
+
+// ----------------------------------------------------------------------------------
+// xml-узел
+type Node struct {
+	Name     string  // имя тега
+	Attrs    []Attr  // атрибуты (в порядке следования в файле)
+	Children []*Node // дочерние узлы
+	Parent   *Node
+}
+
+// ----------------------------------------------------------------------------------
+type Attr struct {
+	Name  string
+	Value string
+}
+
+// ----------------------------------------------------------------------------------
+// Получить значение атрибута (пустая строка если атрибута нет)
+func (n *Node) Attr(name string) string {
+
+	v, _ := n.LookupAttr(name)
+	return v
+}
+
+// ----------------------------------------------------------------------------------
+// Получить значение атрибута с признаком наличия
+func (n *Node) LookupAttr(name string) (string, bool) {
+
+	for _, a := range n.Attrs {
+		if a.Name == name {
+			return a.Value, true
+		}
+	}
+
+	return "", false
+}
+
+// ----------------------------------------------------------------------------------
+// Найти первый дочерний узел (на любом уровне вложенности) с указанным тегом
+func (n *Node) Find(tag string) *Node {
+
+	for _, c := range n.Children {
+		if c.Name == tag {
+			return c
+		}
+	}
+
+	for _, c := range n.Children {
+		if f := c.Find(tag); f != nil {
+			return f
+		}
+	}
+
+	return nil
+}
+
+// ----------------------------------------------------------------------------------
+// Найти первый дочерний узел (на любом уровне вложенности) у которого name="..."
+func (n *Node) FindByName(name string) *Node {
+
+	for _, c := range n.Children {
+		if c.Attr("name") == name {
+			return c
+		}
+
+		if f := c.FindByName(name); f != nil {
+			return f
+		}
+	}
+
+	return nil
+}
+
+// ----------------------------------------------------------------------------------
+// Непосредственный дочерний узел с указанным тегом
+func (n *Node) Child(tag string) *Node {
+
+	for _, c := range n.Children {
+		if c.Name == tag {
+			return c
+		}
+	}
+
+	return nil
+}
+
+// ----------------------------------------------------------------------------------
+func parseNodes(data []byte) (*Node, error) {
+
+	dec := xml.NewDecoder(bytes.NewReader(data))
+
+	var root *Node
+	var cur *Node
+
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+
+			n := &Node{Name: t.Name.Local, Parent: cur}
+			for _, a := range t.Attr {
+				n.Attrs = append(n.Attrs, Attr{a.Name.Local, strings.TrimSpace(a.Value)})
+			}
+
+			if cur == nil {
+				if root == nil {
+					root = n
+				}
+			} else {
+				cur.Children = append(cur.Children, n)
+			}
+
+			cur = n
+
+		case xml.EndElement:
+			if cur != nil {
+				cur = cur.Parent
+			}
+		}
+	}
+
+	return root, nil
+}
+
+// ----------------------------------------------------------------------------------
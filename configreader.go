@@ -0,0 +1,184 @@
+// Чтение настроек объекта со сбором всех ошибок.
+// В отличие от Init*-функций (которые вызывают panic на первой же ошибке)
+// ConfigReader накапливает ошибки (неверный формат значений, неизвестные датчики,
+// отсутствие обязательных свойств) и позволяет выдать их все одним списком.
+// ---------
+// Пример:
+//
+//	r := uniset.NewConfigReader(cfg)
+//	r.Require("input1_s", "output1_c")
+//	s.sleep_msec = r.Int32("sleep_msec", "150")
+//	s.input1_s = r.SensorID("input1_s", "")
+//	s.output1_c = r.SensorID("output1_c", "")
+//
+//	if err := r.Err(); err != nil {
+//		fmt.Println(err)
+//		os.Exit(1)
+//	}
+//
+// ---------
+package uniset
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ----------------------------------------------------------------------------------
+// Список ошибок конфигурирования объекта
+type ConfigError struct {
+	Name   string // имя объекта
+	Errors []error
+}
+
+func (e *ConfigError) Error() string {
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "(%s): config errors(%d):", e.Name, len(e.Errors))
+
+	for _, err := range e.Errors {
+		fmt.Fprintf(&sb, "\n  - %s", err)
+	}
+
+	return sb.String()
+}
+
+// ----------------------------------------------------------------------------------
+type ConfigReader struct {
+	cfg  *UConfig
+	errs []error
+}
+
+func NewConfigReader(cfg *UConfig) *ConfigReader {
+
+	r := ConfigReader{}
+	r.cfg = cfg
+	return &r
+}
+
+// ----------------------------------------------------------------------------------
+// Накопленные ошибки (nil - если ошибок нет, иначе *ConfigError)
+func (r *ConfigReader) Err() error {
+
+	if len(r.errs) == 0 {
+		return nil
+	}
+
+	return &ConfigError{r.cfg.Name, r.errs}
+}
+
+// ----------------------------------------------------------------------------------
+// Добавить свою ошибку в общий список (например, при дополнительных проверках)
+func (r *ConfigReader) AddError(err error) {
+
+	if err != nil {
+		r.errs = append(r.errs, err)
+	}
+}
+
+// ----------------------------------------------------------------------------------
+// Проверка, что свойства заданы (в командной строке или в конфигурации)
+// Возвращает false, если хотя бы одно свойство не задано
+func (r *ConfigReader) Require(propnames ...string) bool {
+
+	ok := true
+
+	for _, p := range propnames {
+
+		RegisterProp(r.cfg.Name, PropInfo{Name: p, Values: "required"})
+
+		val, err := ReadPropValue(r.cfg, p, "")
+		if err != nil {
+			r.AddError(err)
+			ok = false
+			continue
+		}
+
+		if len(val) == 0 {
+			r.AddError(fmt.Errorf("required property '%s' is not specified", p))
+			ok = false
+		}
+	}
+
+	return ok
+}
+
+// ----------------------------------------------------------------------------------
+// Описание свойства для справки (см. PrintPropsHelp)
+func (r *ConfigReader) Help(propname string, description string) {
+
+	RegisterProp(r.cfg.Name, PropInfo{Name: propname, Description: description})
+}
+
+// ----------------------------------------------------------------------------------
+func (r *ConfigReader) register(propname string, typ string, defval string) {
+
+	RegisterProp(r.cfg.Name, PropInfo{Name: propname, Type: typ, Default: defval})
+}
+
+// ----------------------------------------------------------------------------------
+func (r *ConfigReader) Int32(propname string, defval string) int32 {
+
+	r.register(propname, "int32", defval)
+	v, err := ReadInt32(r.cfg, propname, defval)
+	r.AddError(err)
+	return v
+}
+
+func (r *ConfigReader) Int64(propname string, defval string) int64 {
+
+	r.register(propname, "int64", defval)
+	v, err := ReadInt64(r.cfg, propname, defval)
+	r.AddError(err)
+	return v
+}
+
+func (r *ConfigReader) Float32(propname string, defval string) float32 {
+
+	r.register(propname, "float32", defval)
+	v, err := ReadFloat32(r.cfg, propname, defval)
+	r.AddError(err)
+	return v
+}
+
+func (r *ConfigReader) Float64(propname string, defval string) float64 {
+
+	r.register(propname, "float64", defval)
+	v, err := ReadFloat64(r.cfg, propname, defval)
+	r.AddError(err)
+	return v
+}
+
+func (r *ConfigReader) Bool(propname string, defval string) bool {
+
+	r.register(propname, "bool", defval)
+	v, err := ReadBool(r.cfg, propname, defval)
+	r.AddError(err)
+	return v
+}
+
+func (r *ConfigReader) String(propname string, defval string) string {
+
+	r.register(propname, "string", defval)
+	v, err := ReadString(r.cfg, propname, defval)
+	r.AddError(err)
+	return v
+}
+
+func (r *ConfigReader) SensorID(propname string, defval string) ObjectID {
+
+	r.register(propname, "sensor", defval)
+	v, err := ReadSensorID(r.cfg, propname, defval)
+	r.AddError(err)
+	return v
+}
+
+func (r *ConfigReader) ObjectID(propname string, defval string) ObjectID {
+
+	r.register(propname, "object", defval)
+	v, err := ReadObjectID(r.cfg, propname, defval)
+	r.AddError(err)
+	return v
+}
+
+// ----------------------------------------------------------------------------------
@@ -0,0 +1,185 @@
+// Реестр заказчиков датчиков (используется только из одной go-рутины: mainLoop UProxy
+// или go-рутины своей части датчиков в режиме с разделением, см. dispatch.go, поэтому без блокировок).
+// Для каждого датчика хранится список заказчиков (с индексом для добавления/удаления за O(1)),
+// а для каждого объекта - список заказанных им датчиков (для быстрого отказа от всех заказов).
+// ---------
+package uniset
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ----------------------------------------------------------------------------------
+// список заказчиков датчика
+// (порядок рассылки не гарантируется: при удалении на место удалённого ставится последний)
+// Храним сразу delivery объекта, чтобы при рассылке не обращаться к общим структурам UProxy
+type consumersList struct {
+	items []*delivery
+	index map[ObjectID]int // позиция объекта в items
+}
+
+// ----------------------------------------------------------------------------------
+func newConsumersList() *consumersList {
+
+	lst := consumersList{}
+	lst.index = make(map[ObjectID]int)
+	return &lst
+}
+
+// ----------------------------------------------------------------------------------
+// возвращает false, если объект уже был в списке
+func (l *consumersList) add(cons *delivery) bool {
+
+	id := cons.obj.ID()
+
+	if _, found := l.index[id]; found {
+		return false
+	}
+
+	l.index[id] = len(l.items)
+	l.items = append(l.items, cons)
+	return true
+}
+
+// ----------------------------------------------------------------------------------
+// возвращает false, если объекта не было в списке
+func (l *consumersList) remove(id ObjectID) bool {
+
+	i, found := l.index[id]
+	if !found {
+		return false
+	}
+
+	last := len(l.items) - 1
+
+	if i != last {
+		l.items[i] = l.items[last]
+		l.index[l.items[i].obj.ID()] = i
+	}
+
+	l.items[last] = nil
+	l.items = l.items[:last]
+	delete(l.index, id)
+
+	return true
+}
+
+// ----------------------------------------------------------------------------------
+func (l *consumersList) contains(id ObjectID) bool {
+
+	_, found := l.index[id]
+	return found
+}
+
+// ----------------------------------------------------------------------------------
+func (l *consumersList) size() int {
+	return len(l.items)
+}
+
+// ----------------------------------------------------------------------------------
+func (l *consumersList) String() string {
+
+	var sb strings.Builder
+	sb.WriteString("[")
+
+	for _, c := range l.items {
+		fmt.Fprintf(&sb, " %d", c.obj.ID())
+	}
+
+	sb.WriteString(" ]")
+	return sb.String()
+}
+
+// ----------------------------------------------------------------------------------
+// реестр заказов: датчик --> заказчики, объект --> датчики
+type askRegistry struct {
+	bySensor map[ObjectID]*consumersList
+	byObject map[ObjectID]map[ObjectID]struct{}
+}
+
+// ----------------------------------------------------------------------------------
+func newAskRegistry() *askRegistry {
+
+	r := askRegistry{}
+	r.bySensor = make(map[ObjectID]*consumersList)
+	r.byObject = make(map[ObjectID]map[ObjectID]struct{})
+	return &r
+}
+
+// ----------------------------------------------------------------------------------
+// заказчики датчика (nil - если датчик никем не заказан)
+func (r *askRegistry) consumers(sid ObjectID) *consumersList {
+	return r.bySensor[sid]
+}
+
+// ----------------------------------------------------------------------------------
+// добавить заказчика
+func (r *askRegistry) add(sid ObjectID, cons *delivery) {
+
+	lst, found := r.bySensor[sid]
+	if !found {
+		lst = newConsumersList()
+		r.bySensor[sid] = lst
+	}
+
+	if !lst.add(cons) {
+		return
+	}
+
+	sensors, found := r.byObject[cons.obj.ID()]
+	if !found {
+		sensors = make(map[ObjectID]struct{})
+		r.byObject[cons.obj.ID()] = sensors
+	}
+
+	sensors[sid] = struct{}{}
+}
+
+// ----------------------------------------------------------------------------------
+// удалить заказчика
+// возвращает true, если это был последний заказчик датчика
+func (r *askRegistry) remove(sid ObjectID, id ObjectID) bool {
+
+	lst, found := r.bySensor[sid]
+	if !found || !lst.remove(id) {
+		return false
+	}
+
+	if sensors, found := r.byObject[id]; found {
+		delete(sensors, sid)
+		if len(sensors) == 0 {
+			delete(r.byObject, id)
+		}
+	}
+
+	if lst.size() > 0 {
+		return false
+	}
+
+	delete(r.bySensor, sid)
+	return true
+}
+
+// ----------------------------------------------------------------------------------
+func (r *askRegistry) contains(sid ObjectID, id ObjectID) bool {
+
+	lst, found := r.bySensor[sid]
+	return found && lst.contains(id)
+}
+
+// ----------------------------------------------------------------------------------
+// список датчиков заказанных объектом
+func (r *askRegistry) sensors(id ObjectID) []ObjectID {
+
+	sensors := r.byObject[id]
+
+	list := make([]ObjectID, 0, len(sensors))
+	for sid := range sensors {
+		list = append(list, sid)
+	}
+
+	return list
+}
+
+// ----------------------------------------------------------------------------------
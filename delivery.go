@@ -0,0 +1,501 @@
+// Политики доставки сообщений объектам (см. UProxy.SetDeliveryPolicy).
+// Если объект не успевает разбирать свой канал событий, то в зависимости от политики:
+//
+//	DeliverDropNewest - новое сообщение отбрасывается (поведение по умолчанию)
+//	DeliverBlock      - ожидание освобождения канала, но не дольше Timeout
+//	DeliverDropOldest - сообщения копятся в очереди UProxy (QueueSize), при переполнении
+//	                    отбрасывается самое старое SensorEvent
+//	DeliverLatest     - в очереди UProxy хранится только последнее значение по каждому датчику,
+//	                    поэтому объект в итоге всегда получает актуальное состояние
+//
+// У каждого объекта своя очередь в UProxy (QueueSize) и своя go-рутина доставки,
+// поэтому медленный объект (в том числе ожидание в DeliverBlock) не задерживает рассылку другим.
+// Для DeliverDropNewest и DeliverBlock при переполнении очереди отбрасывается новое SensorEvent.
+// После Remove (или завершения работы UProxy) go-рутина досылает оставшееся в очереди не дольше Timeout,
+// после чего недоставленные сообщения отбрасываются, а канал событий объекта закрывается.
+// Служебные сообщения (ActivateEvent, FinishEvent, ответы на команды) в очереди не отбрасываются.
+// Количество отброшенных сообщений можно узнать через UProxy.DeliveryStats(),
+// а для оперативной реакции установить обработчик UProxy.SetDropHandler()
+// ---------
+// Датчики, по которым были отброшены SensorEvent, запоминаются, и как только объект
+// начнёт разбирать свой канал, UProxy присылает ему текущие значения этих датчиков
+// (SensorEvent с признаком Resync), чтобы копия входов объекта не расходилась с SM.
+// Запрос на досылку делается, когда очередь объекта в UProxy опустела, а сами Resync-события
+// доставляются без ограничения по времени (т.е. приходят, как только в канале объекта появится место).
+//
+// Timestamp у Resync-событий - время досылки (time.Now()), а не время изменения датчика.
+// ---------
+package uniset
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ----------------------------------------------------------------------------------
+type DeliveryMode int
+
+const (
+	DeliverDropNewest DeliveryMode = iota
+	DeliverBlock
+	DeliverDropOldest
+	DeliverLatest
+)
+
+// время ожидания (см. DeliveryPolicy.Timeout), если Timeout не задан
+const DefaultDeliveryTimeout = time.Second
+
+func (m DeliveryMode) String() string {
+
+	switch m {
+	case DeliverDropNewest:
+		return "drop-newest"
+	case DeliverBlock:
+		return "block"
+	case DeliverDropOldest:
+		return "drop-oldest"
+	case DeliverLatest:
+		return "latest"
+	}
+
+	return fmt.Sprintf("DeliveryMode(%d)", int(m))
+}
+
+// ----------------------------------------------------------------------------------
+type DeliveryPolicy struct {
+	Mode DeliveryMode
+
+	// DeliverBlock - время ожидания места в канале объекта (для каждого SensorEvent),
+	// для всех режимов - время досылки очереди после Remove (завершения работы)
+	Timeout time.Duration
+
+	QueueSize int // размер очереди в UProxy (0 - размер канала событий объекта)
+}
+
+// ----------------------------------------------------------------------------------
+// статистика доставки для объекта
+type DeliveryStat struct {
+	ID      ObjectID
+	Mode    DeliveryMode
+	Dropped uint64 // количество отброшенных сообщений
+	Pending int    // количество сообщений ожидающих доставки в очереди UProxy
+}
+
+// ----------------------------------------------------------------------------------
+// состояние доставки для объекта
+type delivery struct {
+	obj     UObject
+	policy  DeliveryPolicy
+	dropped atomic.Uint64
+	box     *outbox // очередь и go-рутина доставки
+	onDrop  func(id ObjectID, msg *UMessage)
+
+	// датчики по которым были потеряны события (для досылки текущих значений)
+	lostmut   sync.Mutex
+	lost      map[ObjectID]struct{}
+	requested bool            // запрос на досылку уже отправлен в UProxy
+	resync    chan<- ObjectID // запрос в UProxy на досылку
+}
+
+// ----------------------------------------------------------------------------------
+// wg - учёт go-рутин доставки (чтобы UProxy при завершении мог дождаться их окончания)
+func newDelivery(obj UObject, policy DeliveryPolicy, onDrop func(id ObjectID, msg *UMessage), resync chan<- ObjectID, wg *sync.WaitGroup) *delivery {
+
+	d := delivery{obj: obj, policy: policy, onDrop: onDrop, resync: resync}
+	d.lost = make(map[ObjectID]struct{})
+
+	if d.policy.Timeout <= 0 {
+		d.policy.Timeout = DefaultDeliveryTimeout
+	}
+
+	limit := policy.QueueSize
+	if limit <= 0 {
+		limit = cap(obj.UEvent())
+	}
+
+	if limit <= 0 {
+		limit = 1
+	}
+
+	d.box = newOutbox(&d, limit)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		d.box.run()
+	}()
+
+	return &d
+}
+
+// ----------------------------------------------------------------------------------
+// посылка сообщения объекту (в соответствии с политикой)
+// Не блокирует: сообщение помещается в очередь объекта, доставку делает его go-рутина
+func (d *delivery) send(msg UMessage) {
+
+	d.box.push(msg)
+}
+
+// ----------------------------------------------------------------------------------
+// нужна ли досылка потерянных значений (если да - отмечаем, что запрос отправляется)
+func (d *delivery) needResync() bool {
+
+	d.lostmut.Lock()
+	defer d.lostmut.Unlock()
+
+	if len(d.lost) == 0 || d.requested || d.resync == nil {
+		return false
+	}
+
+	d.requested = true
+	return true
+}
+
+// ----------------------------------------------------------------------------------
+// запрос на досылку не был отправлен
+func (d *delivery) cancelResync() {
+
+	d.lostmut.Lock()
+	defer d.lostmut.Unlock()
+
+	d.requested = false
+}
+
+// ----------------------------------------------------------------------------------
+// отброшенное сообщение
+func (d *delivery) drop(msg *UMessage) {
+
+	if sm, ok := msg.PopAsSensorEvent(); ok {
+		d.markLost(sm.Id)
+	}
+
+	d.countDrop(msg)
+}
+
+// ----------------------------------------------------------------------------------
+// учёт отброшенного сообщения (без досылки, например если в очереди есть более новое значение)
+func (d *delivery) countDrop(msg *UMessage) {
+
+	d.dropped.Add(1)
+
+	if d.onDrop != nil {
+		d.onDrop(d.obj.ID(), msg)
+	}
+}
+
+// ----------------------------------------------------------------------------------
+// запоминаем датчик для досылки (см. outbox.run)
+func (d *delivery) markLost(sid ObjectID) {
+
+	d.lostmut.Lock()
+	defer d.lostmut.Unlock()
+
+	d.lost[sid] = struct{}{}
+}
+
+// ----------------------------------------------------------------------------------
+// забрать список датчиков для досылки
+func (d *delivery) takeLost() []ObjectID {
+
+	d.lostmut.Lock()
+	defer d.lostmut.Unlock()
+
+	list := make([]ObjectID, 0, len(d.lost))
+	for sid := range d.lost {
+		list = append(list, sid)
+	}
+
+	d.lost = make(map[ObjectID]struct{})
+	d.requested = false
+
+	return list
+}
+
+// ----------------------------------------------------------------------------------
+// закрытие канала событий объекта
+// (после доставки того, что осталось в очереди, но не дольше policy.Timeout)
+func (d *delivery) close() {
+
+	d.box.close()
+}
+
+// ----------------------------------------------------------------------------------
+func (d *delivery) stat() DeliveryStat {
+
+	return DeliveryStat{ID: d.obj.ID(), Mode: d.policy.Mode, Dropped: d.dropped.Load(), Pending: d.box.pending()}
+}
+
+// ----------------------------------------------------------------------------------
+// очередь сообщений объекта на стороне UProxy
+// (заполняется из mainLoop, разбирается отдельной go-рутиной)
+type outbox struct {
+	d         *delivery
+	mut       sync.Mutex
+	queue     []UMessage
+	limit     int
+	closed    bool
+	busy      bool // go-рутина доставки занята сообщением, взятым из очереди
+	wake      chan struct{}
+	abort     chan struct{} // прекратить досылку (закрывается через Timeout после close)
+	abortOnce sync.Once
+}
+
+// ----------------------------------------------------------------------------------
+func newOutbox(d *delivery, limit int) *outbox {
+
+	b := outbox{d: d, limit: limit}
+	b.wake = make(chan struct{}, 1)
+	b.abort = make(chan struct{})
+	return &b
+}
+
+// ----------------------------------------------------------------------------------
+func (b *outbox) push(msg UMessage) {
+
+	b.mut.Lock()
+
+	if b.closed {
+		b.mut.Unlock()
+		return
+	}
+
+	// быстрый путь: если доставлять нечего, пробуем сразу положить в канал объекта
+	// (порядок сообщений не нарушается, т.к. go-рутина доставки ничего не отправляет)
+	if len(b.queue) == 0 && !b.busy {
+		select {
+		case b.d.obj.UEvent() <- msg:
+			b.mut.Unlock()
+			return
+		default:
+		}
+	}
+
+	sm, isSensor := msg.PopAsSensorEvent()
+
+	switch {
+	case isSensor && b.d.policy.Mode == DeliverLatest:
+
+		// заменяем ещё не доставленное значение датчика
+		for i := range b.queue {
+			if prev, ok := b.queue[i].PopAsSensorEvent(); ok && prev.Id == sm.Id {
+				old := b.queue[i]
+				b.queue[i] = msg
+				b.mut.Unlock()
+				b.d.countDrop(&old)
+				return
+			}
+		}
+
+	case isSensor && len(b.queue) >= b.limit && b.d.policy.Mode != DeliverDropOldest:
+
+		// выкидываем новое SensorEvent
+		b.mut.Unlock()
+		b.d.drop(&msg)
+		return
+
+	case isSensor && len(b.queue) >= b.limit:
+
+		// выкидываем самое старое SensorEvent
+		for i := range b.queue {
+			if old, ok := b.queue[i].PopAsSensorEvent(); ok {
+				oldmsg := b.queue[i]
+				b.queue = append(b.queue[:i], b.queue[i+1:]...)
+				b.queue = append(b.queue, msg)
+				newer := b.hasSensorEvent(old.Id)
+				b.mut.Unlock()
+
+				if newer {
+					b.d.countDrop(&oldmsg)
+				} else {
+					b.d.drop(&oldmsg)
+				}
+
+				b.notify()
+				return
+			}
+		}
+	}
+
+	b.queue = append(b.queue, msg)
+	b.mut.Unlock()
+	b.notify()
+}
+
+// ----------------------------------------------------------------------------------
+// есть ли в очереди событие по датчику (вызывается под mut)
+func (b *outbox) hasSensorEvent(sid ObjectID) bool {
+
+	for i := range b.queue {
+		if sm, ok := b.queue[i].PopAsSensorEvent(); ok && sm.Id == sid {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ----------------------------------------------------------------------------------
+func (b *outbox) notify() {
+
+	select {
+	case b.wake <- struct{}{}:
+	default:
+	}
+}
+
+// ----------------------------------------------------------------------------------
+// после закрытия новые сообщения не принимаются,
+// а на досылку оставшихся отводится policy.Timeout
+func (b *outbox) close() {
+
+	b.mut.Lock()
+	b.closed = true
+	b.mut.Unlock()
+	b.notify()
+
+	time.AfterFunc(b.d.policy.Timeout, func() {
+		b.abortOnce.Do(func() { close(b.abort) })
+	})
+}
+
+// ----------------------------------------------------------------------------------
+// отбросить сообщение msg и всё, что осталось в очереди
+func (b *outbox) discard(msg UMessage) {
+
+	b.mut.Lock()
+	rest := b.queue
+	b.queue = nil
+	b.mut.Unlock()
+
+	b.d.countDrop(&msg)
+	for i := range rest {
+		b.d.countDrop(&rest[i])
+	}
+}
+
+// ----------------------------------------------------------------------------------
+func (b *outbox) pending() int {
+
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	return len(b.queue)
+}
+
+// ----------------------------------------------------------------------------------
+// go-рутина доставки
+func (b *outbox) run() {
+
+	for {
+		b.mut.Lock()
+		b.busy = false
+
+		if len(b.queue) == 0 {
+
+			closed := b.closed
+			b.mut.Unlock()
+
+			if closed {
+				close(b.d.obj.UEvent())
+				return
+			}
+
+			// очередь разобрана: если были потери, просим UProxy дослать текущие значения
+			// (Resync-события придут сюда же и будут ждать места в канале объекта)
+			if b.d.needResync() {
+				select {
+				case b.d.resync <- b.d.obj.ID():
+				case <-b.wake:
+					b.d.cancelResync()
+				case <-b.abort:
+					b.d.cancelResync()
+				}
+				continue
+			}
+
+			select {
+			case <-b.wake:
+			case <-b.abort:
+			}
+			continue
+		}
+
+		msg := b.queue[0]
+		b.queue[0] = UMessage{}
+		b.queue = b.queue[1:]
+		b.busy = true
+		b.mut.Unlock()
+
+		if !b.deliver(msg) {
+			// объект так и не разобрал свой канал
+			b.discard(msg)
+			close(b.d.obj.UEvent())
+			return
+		}
+	}
+}
+
+// ----------------------------------------------------------------------------------
+// доставка одного сообщения в канал объекта (в соответствии с политикой)
+// false - доставка прервана (abort), сообщение не доставлено
+func (b *outbox) deliver(msg UMessage) bool {
+
+	// служебные сообщения и Resync-события ждут места в канале без ограничения
+	// (до abort), ограничения политики касаются только обычных SensorEvent
+	sm, isSensor := msg.PopAsSensorEvent()
+	limited := isSensor && !sm.Resync
+
+	switch {
+	case limited && b.d.policy.Mode == DeliverDropNewest:
+
+		select {
+		case b.d.obj.UEvent() <- msg:
+		default:
+			b.dropSent(msg, sm.Id)
+		}
+
+		return true
+
+	case limited && b.d.policy.Mode == DeliverBlock:
+
+		t := time.NewTimer(b.d.policy.Timeout)
+		defer t.Stop()
+
+		select {
+		case b.d.obj.UEvent() <- msg:
+		case <-t.C:
+			b.dropSent(msg, sm.Id)
+		case <-b.abort:
+			return false
+		}
+
+		return true
+	}
+
+	select {
+	case b.d.obj.UEvent() <- msg:
+		return true
+	case <-b.abort:
+		return false
+	}
+}
+
+// ----------------------------------------------------------------------------------
+// отбросить сообщение, которое не удалось доставить
+// (если в очереди есть более новое значение датчика, досылка не нужна)
+func (b *outbox) dropSent(msg UMessage, sid ObjectID) {
+
+	b.mut.Lock()
+	newer := b.hasSensorEvent(sid)
+	b.mut.Unlock()
+
+	if newer {
+		b.d.countDrop(&msg)
+		return
+	}
+
+	b.d.drop(&msg)
+}
+
+// ----------------------------------------------------------------------------------
@@ -0,0 +1,180 @@
+// Режим рассылки событий с разделением по датчикам (см. UProxy.SetDispatchShards).
+// Датчики делятся на N частей (по sensor ID), каждую обслуживает своя go-рутина
+// со своим списком заказчиков (askRegistry). SensorEvent от backend-а сразу направляются
+// в go-рутину своего датчика, поэтому порядок событий по каждому датчику сохраняется.
+// Команды заказа/отказа (и досылка потерянных значений) выполняются той же go-рутиной.
+// Выигрыш возможен только при нескольких процессорах (GOMAXPROCS > 1) и большом числе
+// заказчиков на датчик, поэтому по умолчанию используется одна go-рутина (mainLoop).
+// ---------
+// Замечание: в этом режиме заказ/отказ и чтение значений делаются из нескольких go-рутин,
+// поэтому вызовы Backend (AskSensor, UnaskSensor, GetValue, SetValue) из UProxy сериализуются
+// (см. lockBackend) и поддержка одновременных вызовов от Backend не требуется.
+// На рассылку SensorEvent это не влияет - она идёт без обращений к Backend.
+// ---------
+package uniset
+
+import (
+	"context"
+	"errors"
+)
+
+// ----------------------------------------------------------------------------------
+type dispatchShard struct {
+	askmap   *askRegistry
+	msg      chan *SensorEvent
+	ops      chan func(reg *askRegistry)
+	finished chan struct{} // закрывается при завершении go-рутины
+}
+
+// ----------------------------------------------------------------------------------
+func newDispatchShard(qsize uint) *dispatchShard {
+
+	s := dispatchShard{}
+	s.askmap = newAskRegistry()
+	s.msg = make(chan *SensorEvent, qsize)
+	s.ops = make(chan func(reg *askRegistry), qsize)
+	s.finished = make(chan struct{})
+	return &s
+}
+
+// ----------------------------------------------------------------------------------
+func (s *dispatchShard) run(ctx context.Context, ui *UProxy) {
+
+	defer close(s.finished)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case fn := <-s.ops:
+			fn(s.askmap)
+
+		case msg := <-s.msg:
+			ui.doSensorEvent(s.askmap, msg)
+		}
+	}
+}
+
+// ----------------------------------------------------------------------------------
+// Включить режим рассылки с разделением на n go-рутин (n <= 1 - одна go-рутина, по умолчанию)
+// Задавать надо до запуска (Run)
+func (ui *UProxy) SetDispatchShards(n int) error {
+
+	ui.runmutex.Lock()
+	defer ui.runmutex.Unlock()
+
+	if ui.IsActive() {
+		return errors.New("(UProxy.SetDispatchShards): can not be changed while running")
+	}
+
+	ui.nshards = n
+	return nil
+}
+
+// ----------------------------------------------------------------------------------
+func (ui *UProxy) startShards(ctx context.Context) {
+
+	ui.shards = nil
+
+	if ui.nshards <= 1 {
+		return
+	}
+
+	for i := 0; i < ui.nshards; i++ {
+
+		s := newDispatchShard(ui.mqSize)
+		ui.shards = append(ui.shards, s)
+
+		ui.shardwg.Add(1)
+		go func() {
+			defer ui.shardwg.Done()
+			s.run(ctx, ui)
+		}()
+	}
+}
+
+// ----------------------------------------------------------------------------------
+func (ui *UProxy) shardFor(sid ObjectID) *dispatchShard {
+	return ui.shards[uint64(sid)%uint64(len(ui.shards))]
+}
+
+// ----------------------------------------------------------------------------------
+// очередь в которую надо направлять события датчика
+func (ui *UProxy) eventQueue(sid ObjectID) chan<- *SensorEvent {
+
+	if len(ui.shards) == 0 {
+		return ui.msg
+	}
+
+	return ui.shardFor(sid).msg
+}
+
+// ----------------------------------------------------------------------------------
+// выполнить fn со списком заказчиков, в котором находится датчик sid
+// (в режиме с разделением - асинхронно, в go-рутине обслуживающей датчик)
+func (ui *UProxy) withRegistry(sid ObjectID, fn func(reg *askRegistry)) {
+
+	if len(ui.shards) == 0 {
+		fn(ui.askmap)
+		return
+	}
+
+	s := ui.shardFor(sid)
+
+	select {
+	case s.ops <- fn:
+	case <-s.finished:
+	}
+}
+
+// ----------------------------------------------------------------------------------
+// выполнить fn для всех списков заказчиков и дождаться завершения
+func (ui *UProxy) forEachRegistry(fn func(reg *askRegistry)) {
+
+	if len(ui.shards) == 0 {
+		fn(ui.askmap)
+		return
+	}
+
+	done := make([]chan struct{}, len(ui.shards))
+
+	for i, s := range ui.shards {
+
+		ch := make(chan struct{})
+		done[i] = ch
+
+		select {
+		case s.ops <- func(reg *askRegistry) { fn(reg); close(ch) }:
+		case <-s.finished:
+			close(ch)
+		}
+	}
+
+	for i, s := range ui.shards {
+		select {
+		case <-done[i]:
+		case <-s.finished:
+		}
+	}
+}
+
+// ----------------------------------------------------------------------------------
+// вызовы backend из обработки команд и рассылки
+// (в режиме с разделением они идут из разных go-рутин, поэтому сериализуются;
+// набор shards не меняется во время работы, поэтому lock/unlock всегда парные)
+func (ui *UProxy) lockBackend() {
+
+	if len(ui.shards) > 0 {
+		ui.bmutex.Lock()
+	}
+}
+
+func (ui *UProxy) unlockBackend() {
+
+	if len(ui.shards) > 0 {
+		ui.bmutex.Unlock()
+	}
+}
+
+// ----------------------------------------------------------------------------------
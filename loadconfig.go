@@ -0,0 +1,411 @@
+// Заполнение структуры настроек объекта по тегам prop (вместо вызова Init*-функций для каждого поля).
+// Значения выбираются с тем же приоритетом, что и в PropValueByName:
+// по списку источников (см. PropSources: командная строка --name-prop, окружение,
+// секция настроек объекта), затем default из тега.
+// Формат тега:
+//
+//	prop:"sleep_msec,default=150ms,min=10ms,max=10s"  - длительность ("500ms", "2s" или число в unit)
+//	prop:"mode,default=auto,enum=auto|manual|off"     - допустимые значения
+//	prop:"level,min=0,max=100,required"               - обязательное свойство с проверкой диапазона
+//	prop:"input1"                                     - для полей ObjectID: имя датчика
+//	prop:"target,object"                              - для полей ObjectID: имя объекта
+//	prop:"timeout,unit=s"                             - единицы для длительности заданной числом (по умолчанию ms)
+//
+// Описание свойства для справки (см. PrintPropsHelp) задаётся отдельным тегом help:"...".
+//
+// Поддерживаются поля типов string, bool, целочисленные, float32/float64, time.Duration и ObjectID.
+// Если значение не задано (и нет default), поле не изменяется (ObjectID выставляется в DefaultObjectID).
+// Вложенные (анонимные) структуры обходятся рекурсивно.
+// Все найденные ошибки возвращаются вместе (см. ConfigError).
+// ---------
+// Пример:
+//
+//	type MyParams struct {
+//		Sleep  time.Duration   `prop:"sleep_msec,default=150,min=10"`
+//		Mode   string          `prop:"mode,default=auto,enum=auto|manual"`
+//		Input1 uniset.ObjectID `prop:"input1,required"`
+//	}
+//
+//	p := MyParams{}
+//	err := uniset.LoadConfig(cfg, &p)
+//
+// ---------
+package uniset
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ----------------------------------------------------------------------------------
+// разобранный тег prop:"..."
+type propTag struct {
+	name     string
+	defval   string
+	min      string
+	max      string
+	enum     []string
+	unit     time.Duration
+	required bool
+	object   bool
+}
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	objectIDType = reflect.TypeOf(ObjectID(0))
+)
+
+// ----------------------------------------------------------------------------------
+// Заполнение полей структуры obj (указатель на структуру) из настроек объекта
+func LoadConfig(cfg *UConfig, obj interface{}) error {
+
+	rv := reflect.ValueOf(obj)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("(uniset.LoadConfig): obj must be a non-nil pointer to struct")
+	}
+
+	var errs []error
+	loadStruct(rv.Elem(), cfg, &errs)
+
+	if len(errs) > 0 {
+		return &ConfigError{cfg.Name, errs}
+	}
+
+	return nil
+}
+
+// ----------------------------------------------------------------------------------
+func loadStruct(sv reflect.Value, cfg *UConfig, errs *[]error) {
+
+	st := sv.Type()
+
+	for i := 0; i < st.NumField(); i++ {
+
+		f := st.Field(i)
+		fv := sv.Field(i)
+
+		tagstr, found := f.Tag.Lookup("prop")
+
+		if !found {
+			if f.Anonymous && fv.Kind() == reflect.Struct {
+				loadStruct(fv, cfg, errs)
+			}
+			continue
+		}
+
+		if !f.IsExported() {
+			*errs = append(*errs, fmt.Errorf("field '%s' is not exported", f.Name))
+			continue
+		}
+
+		tag, err := parsePropTag(tagstr)
+		if err != nil {
+			*errs = append(*errs, fmt.Errorf("field '%s': %s", f.Name, err))
+			continue
+		}
+
+		RegisterProp(cfg.Name, PropInfo{
+			Name:        tag.name,
+			Type:        propTypeName(fv.Type(), tag),
+			Default:     tag.defval,
+			Values:      tag.values(),
+			Description: f.Tag.Get("help"),
+		})
+
+		if err := loadField(fv, tag, cfg); err != nil {
+			*errs = append(*errs, fmt.Errorf("property '%s': %s", tag.name, err))
+		}
+	}
+}
+
+// ----------------------------------------------------------------------------------
+func parsePropTag(s string) (*propTag, error) {
+
+	parts := strings.Split(s, ",")
+
+	tag := propTag{unit: time.Millisecond}
+	tag.name = strings.TrimSpace(parts[0])
+
+	if len(tag.name) == 0 {
+		return nil, fmt.Errorf("bad tag '%s' (property name is not specified)", s)
+	}
+
+	for _, p := range parts[1:] {
+
+		kv := strings.SplitN(strings.TrimSpace(p), "=", 2)
+
+		if len(kv) == 1 {
+			switch kv[0] {
+			case "required":
+				tag.required = true
+			case "object":
+				tag.object = true
+			default:
+				return nil, fmt.Errorf("unknown tag option '%s'", kv[0])
+			}
+			continue
+		}
+
+		switch kv[0] {
+		case "default":
+			tag.defval = kv[1]
+		case "min":
+			tag.min = kv[1]
+		case "max":
+			tag.max = kv[1]
+		case "enum":
+			tag.enum = strings.Split(kv[1], "|")
+		case "unit":
+			u, err := time.ParseDuration("1" + kv[1])
+			if err != nil {
+				return nil, fmt.Errorf("bad unit '%s'", kv[1])
+			}
+			tag.unit = u
+		default:
+			return nil, fmt.Errorf("unknown tag option '%s'", kv[0])
+		}
+	}
+
+	return &tag, nil
+}
+
+// ----------------------------------------------------------------------------------
+// допустимые значения (для справки)
+func (tag *propTag) values() string {
+
+	var list []string
+
+	if tag.required {
+		list = append(list, "required")
+	}
+
+	if len(tag.enum) > 0 {
+		list = append(list, strings.Join(tag.enum, "|"))
+	}
+
+	if len(tag.min) > 0 || len(tag.max) > 0 {
+		list = append(list, fmt.Sprintf("%s..%s", tag.min, tag.max))
+	}
+
+	return strings.Join(list, ", ")
+}
+
+// ----------------------------------------------------------------------------------
+func propTypeName(t reflect.Type, tag *propTag) string {
+
+	switch {
+	case t == durationType:
+		return "duration"
+	case t == objectIDType && tag.object:
+		return "object"
+	case t == objectIDType:
+		return "sensor"
+	}
+
+	return t.Kind().String()
+}
+
+// ----------------------------------------------------------------------------------
+func loadField(fv reflect.Value, tag *propTag, cfg *UConfig) error {
+
+	sval, err := ReadPropValue(cfg, tag.name, tag.defval)
+	if err != nil {
+		return err
+	}
+
+	if len(sval) == 0 {
+
+		if tag.required {
+			return errors.New("required property is not specified")
+		}
+
+		if fv.Type() == objectIDType {
+			fv.SetInt(int64(DefaultObjectID))
+		}
+
+		return nil
+	}
+
+	if len(tag.enum) > 0 && !containsString(tag.enum, sval) {
+		return fmt.Errorf("bad value '%s' (must be one of: %s)", sval, strings.Join(tag.enum, ", "))
+	}
+
+	v, err := parsePropValue(fv.Type(), sval, tag)
+	if err != nil {
+		return err
+	}
+
+	if len(tag.min) > 0 || len(tag.max) > 0 {
+		if err := checkPropRange(v, tag); err != nil {
+			return err
+		}
+	}
+
+	fv.Set(v)
+	return nil
+}
+
+// ----------------------------------------------------------------------------------
+// преобразование строки в значение типа t
+func parsePropValue(t reflect.Type, sval string, tag *propTag) (reflect.Value, error) {
+
+	v := reflect.New(t).Elem()
+
+	switch {
+	case t == durationType:
+
+		d, err := parseDuration(sval, tag.unit)
+		if err != nil {
+			return v, err
+		}
+		v.SetInt(int64(d))
+		return v, nil
+
+	case t == objectIDType:
+
+		var id ObjectID
+		if tag.object {
+			id = getObjectID(sval)
+		} else {
+			id = getSensorID(sval)
+		}
+
+		if id == DefaultObjectID {
+			if tag.object {
+				return v, fmt.Errorf("unknown object '%s'", sval)
+			}
+			return v, fmt.Errorf("unknown sensor '%s'", sval)
+		}
+
+		v.SetInt(int64(id))
+		return v, nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		v.SetString(sval)
+
+	case reflect.Bool:
+		b, err := strconv.ParseBool(sval)
+		if err != nil {
+			return v, fmt.Errorf("bad bool value '%s'", sval)
+		}
+		v.SetBool(b)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(sval, 10, t.Bits())
+		if err != nil {
+			return v, fmt.Errorf("bad integer value '%s'", sval)
+		}
+		v.SetInt(i)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(sval, 10, t.Bits())
+		if err != nil {
+			return v, fmt.Errorf("bad unsigned value '%s'", sval)
+		}
+		v.SetUint(u)
+
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(sval, t.Bits())
+		if err != nil {
+			return v, fmt.Errorf("bad float value '%s'", sval)
+		}
+		v.SetFloat(f)
+
+	default:
+		return v, fmt.Errorf("unsupported type %s", t)
+	}
+
+	return v, nil
+}
+
+// ----------------------------------------------------------------------------------
+// длительность: "500ms", "1m30s" или просто число (в единицах unit)
+func parseDuration(sval string, unit time.Duration) (time.Duration, error) {
+
+	if i, err := strconv.ParseInt(sval, 10, 64); err == nil {
+		return time.Duration(i) * unit, nil
+	}
+
+	d, err := time.ParseDuration(sval)
+	if err != nil {
+		return 0, fmt.Errorf("bad duration '%s'", sval)
+	}
+
+	return d, nil
+}
+
+// ----------------------------------------------------------------------------------
+func checkPropRange(v reflect.Value, tag *propTag) error {
+
+	val, ok := numericValue(v)
+	if !ok || v.Type() == objectIDType {
+		return fmt.Errorf("min/max is not supported for type %s", v.Type())
+	}
+
+	if len(tag.min) > 0 {
+		m, err := parsePropValue(v.Type(), tag.min, tag)
+		if err != nil {
+			return fmt.Errorf("bad min: %s", err)
+		}
+		if mval, _ := numericValue(m); val < mval {
+			return fmt.Errorf("value %s is less than min=%s", formatPropValue(v), tag.min)
+		}
+	}
+
+	if len(tag.max) > 0 {
+		m, err := parsePropValue(v.Type(), tag.max, tag)
+		if err != nil {
+			return fmt.Errorf("bad max: %s", err)
+		}
+		if mval, _ := numericValue(m); val > mval {
+			return fmt.Errorf("value %s is greater than max=%s", formatPropValue(v), tag.max)
+		}
+	}
+
+	return nil
+}
+
+// ----------------------------------------------------------------------------------
+func numericValue(v reflect.Value) (float64, bool) {
+
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+
+	return 0, false
+}
+
+// ----------------------------------------------------------------------------------
+func formatPropValue(v reflect.Value) string {
+
+	if v.Type() == durationType {
+		return time.Duration(v.Int()).String()
+	}
+
+	return fmt.Sprintf("%v", v.Interface())
+}
+
+// ----------------------------------------------------------------------------------
+func containsString(list []string, s string) bool {
+
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ----------------------------------------------------------------------------------
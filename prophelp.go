@@ -0,0 +1,228 @@
+// Описание свойств объектов и автоматическая справка по ним.
+// Объекты регистрируют читаемые свойства (имя, тип, значение по умолчанию, описание).
+// ConfigReader и LoadConfig делают это автоматически, описание можно добавить
+// через ConfigReader.Help или тег help:"..." (для LoadConfig).
+// ---------
+// По --help или --name-help выводится таблица свойств объекта с текущими значениями,
+// а неизвестные аргументы вида --name-xxx считаются ошибкой (см. HandlePropArgs).
+// Свойство регистрируется при любом чтении (ReadPropValue, PropValueByName, Init*, Bind и т.п.),
+// поэтому HandlePropArgs нужно вызывать после чтения всех свойств объекта.
+// ---------
+// Пример:
+//
+//	r := uniset.NewConfigReader(cfg)
+//	r.Help("sleep_msec", "период основного цикла, мсек")
+//	s.sleep_msec = r.Int32("sleep_msec", "150")
+//	...
+//	if err := uniset.HandlePropArgs(cfg); err == uniset.ErrHelp {
+//		os.Exit(0)
+//	} else if err != nil {
+//		...
+//	}
+//
+// ---------
+package uniset
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+)
+
+// ----------------------------------------------------------------------------------
+// Возвращается HandlePropArgs, если была запрошена справка (справка уже выведена)
+var ErrHelp = errors.New("uniset: help requested")
+
+// ----------------------------------------------------------------------------------
+// описание свойства объекта
+type PropInfo struct {
+	Name        string
+	Type        string
+	Default     string
+	Values      string // допустимые значения или диапазон (для справки)
+	Description string
+}
+
+// ----------------------------------------------------------------------------------
+type objectProps struct {
+	order []string
+	props map[string]*PropInfo
+}
+
+var (
+	propRegistry    = make(map[string]*objectProps)
+	propRegistryMut sync.RWMutex
+)
+
+// ----------------------------------------------------------------------------------
+// Регистрация (или дополнение) описания свойства объекта
+// Пустые поля info не затирают ранее зарегистрированные значения
+func RegisterProp(object string, info PropInfo) {
+
+	propRegistryMut.Lock()
+	defer propRegistryMut.Unlock()
+
+	op, found := propRegistry[object]
+	if !found {
+		op = &objectProps{props: make(map[string]*PropInfo)}
+		propRegistry[object] = op
+	}
+
+	p, found := op.props[info.Name]
+	if !found {
+		p = &PropInfo{Name: info.Name}
+		op.props[info.Name] = p
+		op.order = append(op.order, info.Name)
+	}
+
+	if len(info.Type) > 0 {
+		p.Type = info.Type
+	}
+
+	if len(info.Default) > 0 {
+		p.Default = info.Default
+	}
+
+	if len(info.Values) > 0 {
+		p.Values = info.Values
+	}
+
+	if len(info.Description) > 0 {
+		p.Description = info.Description
+	}
+}
+
+// ----------------------------------------------------------------------------------
+// Список зарегистрированных свойств объекта (в порядке регистрации)
+func RegisteredProps(object string) []PropInfo {
+
+	propRegistryMut.RLock()
+	defer propRegistryMut.RUnlock()
+
+	op, found := propRegistry[object]
+	if !found {
+		return nil
+	}
+
+	list := make([]PropInfo, 0, len(op.order))
+	for _, name := range op.order {
+		list = append(list, *op.props[name])
+	}
+
+	return list
+}
+
+// ----------------------------------------------------------------------------------
+// Очистить список зарегистрированных свойств
+// (например при повторной инициализации объектов или в тестах)
+func ResetRegisteredProps() {
+
+	propRegistryMut.Lock()
+	defer propRegistryMut.Unlock()
+
+	propRegistry = make(map[string]*objectProps)
+}
+
+// ----------------------------------------------------------------------------------
+func isRegisteredProp(object string, propname string) bool {
+
+	propRegistryMut.RLock()
+	defer propRegistryMut.RUnlock()
+
+	if op, found := propRegistry[object]; found {
+		_, found = op.props[propname]
+		return found
+	}
+
+	return false
+}
+
+// ----------------------------------------------------------------------------------
+// Запрошена ли справка (--help или --name-help)
+func HelpRequested(object string) bool {
+
+	for _, a := range os.Args[1:] {
+		if a == "--help" || a == fmt.Sprintf("--%s-help", object) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ----------------------------------------------------------------------------------
+// Вывод таблицы свойств объекта (с текущими значениями и их источниками)
+func PrintPropsHelp(w io.Writer, cfg *UConfig) {
+
+	fmt.Fprintf(w, "%s properties (--%s-<prop> value):\n", cfg.Name, cfg.Name)
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "  PROPERTY\tTYPE\tDEFAULT\tVALUE\tDESCRIPTION")
+
+	for _, p := range RegisteredProps(cfg.Name) {
+
+		value := "-"
+		if val, source, found, err := findProp(cfg, p.Name); err == nil && found {
+			value = fmt.Sprintf("%s (%s)", val, source)
+		}
+
+		descr := p.Description
+		if len(p.Values) > 0 {
+			descr = strings.TrimSpace(fmt.Sprintf("%s [%s]", descr, p.Values))
+		}
+
+		fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\t%s\n", p.Name, p.Type, p.Default, value, descr)
+	}
+
+	tw.Flush()
+}
+
+// ----------------------------------------------------------------------------------
+// Проверка аргументов командной строки вида --name-xxx:
+// все xxx должны быть зарегистрированными свойствами объекта
+func CheckUnknownProps(object string) error {
+
+	prefix := fmt.Sprintf("--%s-", object)
+	var unknown []string
+
+	for _, a := range os.Args[1:] {
+
+		if !strings.HasPrefix(a, prefix) {
+			continue
+		}
+
+		prop := strings.TrimPrefix(a, prefix)
+		if prop == "help" || isRegisteredProp(object, prop) {
+			continue
+		}
+
+		unknown = append(unknown, a)
+	}
+
+	if len(unknown) > 0 {
+		return errors.New(fmt.Sprintf("(%s): unknown arguments: %s (see --%s-help)", object, strings.Join(unknown, ", "), object))
+	}
+
+	return nil
+}
+
+// ----------------------------------------------------------------------------------
+// Обработка аргументов свойств объекта (вызывать после чтения всех свойств):
+// при --help или --name-help выводит справку и возвращает ErrHelp
+// (завершать ли программу - решает вызывающий),
+// иначе проверяет, что нет неизвестных аргументов --name-xxx
+func HandlePropArgs(cfg *UConfig) error {
+
+	if HelpRequested(cfg.Name) {
+		PrintPropsHelp(os.Stdout, cfg)
+		return ErrHelp
+	}
+
+	return CheckUnknownProps(cfg.Name)
+}
+
+// ----------------------------------------------------------------------------------
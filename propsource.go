@@ -0,0 +1,426 @@
+// Источники значений свойств объектов.
+// Значение свойства (см. ReadPropValue, PropValueByName, LoadConfig) ищется по списку источников
+// в заданном порядке, первый нашедший значение источник "побеждает", если ни один не нашёл - берётся default.
+// По умолчанию порядок такой:
+//
+//	ArgsSource   - аргумент командной строки --name-prop
+//	EnvSource    - переменная окружения UNISET_<NAME>_<PROP>
+//	ConfigSource - секция настроек объекта в configure.xml (UConfig)
+//
+// Порядок и состав можно поменять через SetPropSources, например добавив файл переопределений:
+//
+//	fsrc, err := uniset.NewFileSource("override.yaml")
+//	...
+//	uniset.SetPropSources(uniset.ArgsSource(), uniset.EnvSource("UNISET"), fsrc, uniset.ConfigSource())
+//
+// Для каждого прочитанного свойства запоминается откуда было взято значение (см. PropOrigins, DumpPropOrigins).
+// Запоминается только последнее чтение каждого свойства, при смене источников (SetPropSources)
+// список сбрасывается (см. также ResetPropOrigins)
+// ---------
+package uniset
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ----------------------------------------------------------------------------------
+// Источник значений свойств
+type PropSource interface {
+	// название источника (для диагностики)
+	Name() string
+
+	// поиск значения свойства propname для объекта cfg.Name
+	// found=false - значение в этом источнике не задано
+	Lookup(cfg *UConfig, propname string) (value string, found bool, err error)
+}
+
+// ----------------------------------------------------------------------------------
+// информация о том, откуда было взято значение свойства
+type PropOrigin struct {
+	Object string
+	Prop   string
+	Value  string
+	Source string // имя источника или "default"
+}
+
+// ----------------------------------------------------------------------------------
+var (
+	propSources   = DefaultPropSources()
+	propOrigins   = make(map[string]PropOrigin)
+	propSourceMut sync.RWMutex
+)
+
+// ----------------------------------------------------------------------------------
+// Список источников по умолчанию (аргументы, окружение, configure.xml)
+func DefaultPropSources() []PropSource {
+	return []PropSource{ArgsSource(), EnvSource("UNISET"), ConfigSource()}
+}
+
+// ----------------------------------------------------------------------------------
+// Задать список источников (в порядке убывания приоритета)
+// Запомненные источники прочитанных ранее свойств (PropOrigins) при этом сбрасываются
+func SetPropSources(sources ...PropSource) {
+
+	propSourceMut.Lock()
+	defer propSourceMut.Unlock()
+
+	propSources = append([]PropSource{}, sources...)
+	propOrigins = make(map[string]PropOrigin)
+}
+
+// ----------------------------------------------------------------------------------
+// Текущий список источников
+func PropSources() []PropSource {
+
+	propSourceMut.RLock()
+	defer propSourceMut.RUnlock()
+
+	return append([]PropSource{}, propSources...)
+}
+
+// ----------------------------------------------------------------------------------
+// поиск значения по списку источников
+func lookupProp(cfg *UConfig, propname string, defval string) (string, error) {
+
+	val, source, found, err := findProp(cfg, propname)
+	if err != nil {
+		return defval, err
+	}
+
+	if !found {
+		val = defval
+		source = "default"
+	}
+
+	recordPropOrigin(cfg.Name, propname, val, source)
+	return val, nil
+}
+
+// ----------------------------------------------------------------------------------
+// поиск значения по списку источников (без учёта default)
+func findProp(cfg *UConfig, propname string) (val string, source string, found bool, err error) {
+
+	for _, s := range PropSources() {
+
+		val, found, err = s.Lookup(cfg, propname)
+		if err != nil || found {
+			return val, s.Name(), found, err
+		}
+	}
+
+	return "", "", false, nil
+}
+
+// ----------------------------------------------------------------------------------
+func recordPropOrigin(object string, prop string, value string, source string) {
+
+	propSourceMut.Lock()
+	defer propSourceMut.Unlock()
+
+	propOrigins[object+"."+prop] = PropOrigin{object, prop, value, source}
+}
+
+// ----------------------------------------------------------------------------------
+// Список прочитанных свойств с указанием источника значения
+// (отсортирован по имени объекта и свойства)
+func PropOrigins() []PropOrigin {
+
+	propSourceMut.RLock()
+	defer propSourceMut.RUnlock()
+
+	list := make([]PropOrigin, 0, len(propOrigins))
+	for _, o := range propOrigins {
+		list = append(list, o)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Object != list[j].Object {
+			return list[i].Object < list[j].Object
+		}
+		return list[i].Prop < list[j].Prop
+	})
+
+	return list
+}
+
+// ----------------------------------------------------------------------------------
+// Очистить список прочитанных свойств (например после вывода DumpPropOrigins при старте)
+func ResetPropOrigins() {
+
+	propSourceMut.Lock()
+	defer propSourceMut.Unlock()
+
+	propOrigins = make(map[string]PropOrigin)
+}
+
+// ----------------------------------------------------------------------------------
+// Вывод списка прочитанных свойств и их источников
+func DumpPropOrigins(w io.Writer) {
+
+	for _, o := range PropOrigins() {
+		fmt.Fprintf(w, "%s.%s = '%s' (%s)\n", o.Object, o.Prop, o.Value, o.Source)
+	}
+}
+
+// ----------------------------------------------------------------------------------
+// Источник: аргументы командной строки вида --name-prop value
+type argsSource struct{}
+
+func ArgsSource() PropSource {
+	return argsSource{}
+}
+
+func (argsSource) Name() string {
+	return "args"
+}
+
+func (argsSource) Lookup(cfg *UConfig, propname string) (string, bool, error) {
+
+	p, err := ReadArgParam(fmt.Sprintf("--%s-%s", cfg.Name, propname), "")
+	return p, len(p) > 0, err
+}
+
+// ----------------------------------------------------------------------------------
+// Источник: переменные окружения вида PREFIX_<NAME>_<PROP>
+// (в верхнем регистре, все символы кроме букв и цифр заменяются на '_')
+// Пустая переменная считается не заданной (как и пустой аргумент командной строки)
+type envSource struct {
+	prefix string
+}
+
+func EnvSource(prefix string) PropSource {
+	return envSource{prefix}
+}
+
+func (s envSource) Name() string {
+	return "env"
+}
+
+func (s envSource) Lookup(cfg *UConfig, propname string) (string, bool, error) {
+
+	val := os.Getenv(EnvPropName(s.prefix, cfg.Name, propname))
+	return val, len(val) > 0, nil
+}
+
+// ----------------------------------------------------------------------------------
+// Имя переменной окружения для свойства объекта
+func EnvPropName(prefix string, object string, propname string) string {
+
+	name := fmt.Sprintf("%s_%s_%s", prefix, object, propname)
+
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9'):
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// ----------------------------------------------------------------------------------
+// Источник: секция настроек объекта из configure.xml (UConfig)
+type configSource struct{}
+
+func ConfigSource() PropSource {
+	return configSource{}
+}
+
+func (configSource) Name() string {
+	return "config"
+}
+
+func (configSource) Lookup(cfg *UConfig, propname string) (string, bool, error) {
+
+	for _, v := range cfg.Config {
+
+		if v.Prop == propname {
+			return v.Value, true, nil
+		}
+	}
+
+	return "", false, nil
+}
+
+// ----------------------------------------------------------------------------------
+// Источник: файл переопределений (json или yaml) вида
+//
+//	{ "TestProc": { "sleep_msec": 200, "mode": "manual" } }
+//
+// или
+//
+//	TestProc:
+//	  sleep_msec: 200
+//	  mode: manual
+//
+// Формат определяется по расширению (.json, .yaml, .yml).
+// Для yaml поддерживается только такое простое подмножество (два уровня вложенности, комментарии #).
+type FileSource struct {
+	name  string
+	props map[string]map[string]string
+}
+
+func NewFileSource(filename string) (*FileSource, error) {
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var props map[string]map[string]string
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		props, err = parseJSONProps(data)
+	case ".yaml", ".yml":
+		props, err = parseYAMLProps(data)
+	default:
+		return nil, errors.New(fmt.Sprintf("(NewFileSource): unknown file format '%s' (must be .json, .yaml or .yml)", filename))
+	}
+
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("(NewFileSource): %s: %s", filename, err))
+	}
+
+	return &FileSource{"file:" + filename, props}, nil
+}
+
+func (s *FileSource) Name() string {
+	return s.name
+}
+
+// Пустое значение ('mode:' или "mode": "") не считается заданным (как и для env и аргументов)
+func (s *FileSource) Lookup(cfg *UConfig, propname string) (string, bool, error) {
+
+	if obj, found := s.props[cfg.Name]; found {
+		val := obj[propname]
+		return val, len(val) > 0, nil
+	}
+
+	return "", false, nil
+}
+
+// ----------------------------------------------------------------------------------
+func parseJSONProps(data []byte) (map[string]map[string]string, error) {
+
+	var raw map[string]map[string]interface{}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	if err := dec.Decode(&raw); err != nil {
+		return nil, err
+	}
+
+	props := make(map[string]map[string]string)
+
+	for obj, list := range raw {
+
+		props[obj] = make(map[string]string)
+
+		for p, v := range list {
+
+			switch v.(type) {
+			case string, json.Number, bool:
+				props[obj][p] = fmt.Sprint(v)
+			default:
+				return nil, errors.New(fmt.Sprintf("%s.%s: unsupported value type (must be string, number or bool)", obj, p))
+			}
+		}
+	}
+
+	return props, nil
+}
+
+// ----------------------------------------------------------------------------------
+func parseYAMLProps(data []byte) (map[string]map[string]string, error) {
+
+	props := make(map[string]map[string]string)
+	var cur map[string]string
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	num := 0
+
+	for scanner.Scan() {
+
+		num++
+		line := scanner.Text()
+
+		if strings.HasPrefix(strings.TrimSpace(line), "#") || len(strings.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		kv := strings.SplitN(line, ":", 2)
+		if len(kv) != 2 {
+			return nil, errors.New(fmt.Sprintf("line %d: bad format (must be 'key: value')", num))
+		}
+
+		key := strings.TrimSpace(kv[0])
+		val := unquote(strings.TrimSpace(stripYAMLComment(kv[1])))
+
+		if line[0] != ' ' && line[0] != '\t' {
+
+			if len(val) != 0 {
+				return nil, errors.New(fmt.Sprintf("line %d: expected object name '%s:'", num, key))
+			}
+
+			cur = make(map[string]string)
+			props[key] = cur
+			continue
+		}
+
+		if cur == nil {
+			return nil, errors.New(fmt.Sprintf("line %d: property '%s' without object", num, key))
+		}
+
+		cur[key] = val
+	}
+
+	return props, scanner.Err()
+}
+
+// ----------------------------------------------------------------------------------
+// удаление комментария из значения: '#' в начале значения или после пробела,
+// внутри значения в кавычках ("a # b") '#' комментарием не считается
+func stripYAMLComment(val string) string {
+
+	val = strings.TrimLeft(val, " \t")
+	start := 0
+
+	if len(val) > 0 && (val[0] == '"' || val[0] == '\'') {
+		if i := strings.IndexByte(val[1:], val[0]); i >= 0 {
+			start = i + 2
+		}
+	}
+
+	for i := start; i < len(val); i++ {
+		if val[i] == '#' && (i == 0 || val[i-1] == ' ' || val[i-1] == '\t') {
+			return val[:i]
+		}
+	}
+
+	return val
+}
+
+// ----------------------------------------------------------------------------------
+func unquote(s string) string {
+
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+
+	return s
+}
+
+// ----------------------------------------------------------------------------------
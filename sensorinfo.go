@@ -0,0 +1,180 @@
+// Информация о датчиках из секции <sensors> configure.xml
+package uniset
+
+import (
+	"fmt"
+	"strconv"
+	"uniset/config"
+)
+
+// ----------------------------------------------------------------------------------
+// тип датчика (iotype)
+type IOType int
+
+const (
+	UnknownIOType IOType = iota
+	DI                   // дискретный вход
+	DO                   // дискретный выход
+	AI                   // аналоговый вход
+	AO                   // аналоговый выход
+)
+
+// ----------------------------------------------------------------------------------
+func (t IOType) String() string {
+
+	switch t {
+	case DI:
+		return "DI"
+	case DO:
+		return "DO"
+	case AI:
+		return "AI"
+	case AO:
+		return "AO"
+	}
+
+	return "UnknownIOType"
+}
+
+// ----------------------------------------------------------------------------------
+func ParseIOType(s string) IOType {
+
+	switch s {
+	case "DI", "di":
+		return DI
+	case "DO", "do":
+		return DO
+	case "AI", "ai":
+		return AI
+	case "AO", "ao":
+		return AO
+	}
+
+	return UnknownIOType
+}
+
+// ----------------------------------------------------------------------------------
+// признак дискретного датчика
+func (t IOType) IsDiscrete() bool {
+	return t == DI || t == DO
+}
+
+// ----------------------------------------------------------------------------------
+type SensorInfo struct {
+	ID       ObjectID
+	Name     string
+	TextName string
+	IOType   IOType
+	Priority string
+	Default  int64
+
+	// все атрибуты из configure.xml (в том числе пользовательские)
+	Attrs map[string]string
+}
+
+// ----------------------------------------------------------------------------------
+// Получить значение атрибута (в том числе пользовательского)
+func (si *SensorInfo) Attr(name string) string {
+	return si.Attrs[name]
+}
+
+// ----------------------------------------------------------------------------------
+// Точность (количество знаков после запятой) из атрибута precision
+// Если атрибут не задан, возвращается 0
+func (si *SensorInfo) Precision() (int, error) {
+
+	p := si.Attr("precision")
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	prec, err := strconv.Atoi(p)
+	if err != nil || prec < 0 {
+		return 0, fmt.Errorf("(SensorInfo): bad precision='%s' for sensor '%s'", p, si.Name)
+	}
+
+	return prec, nil
+}
+
+// ----------------------------------------------------------------------------------
+func (si *SensorInfo) String() string {
+	return fmt.Sprintf("%d: %s (%s) '%s'", si.ID, si.Name, si.IOType, si.TextName)
+}
+
+// ----------------------------------------------------------------------------------
+// Получение информации о датчике по идентификатору
+func SensorInfoByID(sid ObjectID) (*SensorInfo, error) {
+
+	conf, err := requireConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return SensorInfoFromConfig(conf, sid)
+}
+
+// ----------------------------------------------------------------------------------
+// Получение информации о датчике по имени
+func SensorInfoByName(name string) (*SensorInfo, error) {
+
+	conf, err := requireConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	sid := conf.SensorID(name)
+	if sid == config.DefaultObjectID {
+		return nil, fmt.Errorf("(SensorInfoByName): sensor '%s' not found", name)
+	}
+
+	return SensorInfoFromConfig(conf, ObjectID(sid))
+}
+
+// ----------------------------------------------------------------------------------
+// Получение информации о датчике из указанной конфигурации
+func SensorInfoFromConfig(conf *config.Config, sid ObjectID) (*SensorInfo, error) {
+
+	o := conf.ObjectInfoByID(int64(sid))
+	if o == nil || o.Section != config.SectionSensors {
+		return nil, fmt.Errorf("(SensorInfo): sensor id=%d not found", sid)
+	}
+
+	si := SensorInfo{}
+	si.ID = sid
+	si.Name = o.Name
+	si.TextName = o.TextName
+	si.IOType = ParseIOType(o.Attrs["iotype"])
+	si.Priority = o.Attrs["priority"]
+	si.Attrs = o.Attrs
+
+	if d := o.Attrs["default"]; len(d) > 0 {
+		v, err := strconv.ParseInt(d, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("(SensorInfo): bad default='%s' for sensor '%s'", d, o.Name)
+		}
+		si.Default = v
+	}
+
+	return &si, nil
+}
+
+// ----------------------------------------------------------------------------------
+// Проверка, что датчик имеет один из указанных типов
+// (например, для проверки входов и выходов объекта при старте)
+func CheckIOType(sid ObjectID, types ...IOType) error {
+
+	si, err := SensorInfoByID(sid)
+	if err != nil {
+		return err
+	}
+
+	for _, t := range types {
+		if si.IOType == t {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("(CheckIOType): sensor '%s' has iotype=%s, expected %v", si.Name, si.IOType, types)
+}
+
+// ----------------------------------------------------------------------------------
@@ -0,0 +1,337 @@
+// SMemory.
+// Эмулятор SharedMemory работающий внутри процесса (без c++-части и запуска SM).
+// Датчики и их начальные значения (default) загружаются из секции <sensors> файла configure.xml.
+// Поддерживает чтение/запись значений и заказ датчиков.
+// Для каждого UProxy (или UInterface) создаётся свой клиент SMBackend (см. NewBackend),
+// реализующий интерфейс Backend. Уведомления об изменении датчиков клиенту
+// складываются в его очередь и забираются через WaitMessage (аналогично SafeWaitMessage у c++ UProxy)
+// ---------
+// Пример использования в тестах:
+//
+//	sm, err := uniset.NewSMemoryFromFile("configure.xml")
+//	uproxy := uniset.NewUProxyWithBackend("UProxy1", sm.NewBackend(), 2000, 20, 100, 200)
+//	ui := uniset.NewUInterfaceWithBackend(sm.NewBackend())
+//
+// ---------
+package uniset
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+	"uniset/config"
+)
+
+// ----------------------------------------------------------------------------------
+// размер очереди сообщений для клиента по умолчанию
+const DefaultSMQueueSize uint = 1000
+
+// ----------------------------------------------------------------------------------
+type SMemory struct {
+	mut     sync.RWMutex
+	sensors map[ObjectID]*smSensor
+	names   map[string]ObjectID
+	clients map[*SMBackend]bool
+	qsize   uint
+}
+
+// ----------------------------------------------------------------------------------
+// состояние датчика
+type smSensor struct {
+	id        ObjectID
+	name      string
+	value     int64
+	timestamp time.Time
+}
+
+// ----------------------------------------------------------------------------------
+// Клиент эмулятора SharedMemory (реализация Backend)
+type SMBackend struct {
+	sm     *SMemory
+	mut    sync.RWMutex
+	active bool
+	asked  map[ObjectID]bool
+	queue  chan *SensorEvent
+	done   chan struct{}
+}
+
+// ----------------------------------------------------------------------------------
+// Создание пустого эмулятора (датчики можно добавить при помощи AddSensor)
+func NewSMemory() *SMemory {
+	sm := SMemory{}
+	sm.sensors = make(map[ObjectID]*smSensor)
+	sm.names = make(map[string]ObjectID)
+	sm.clients = make(map[*SMBackend]bool)
+	sm.qsize = DefaultSMQueueSize
+	return &sm
+}
+
+// ----------------------------------------------------------------------------------
+// Создание эмулятора с датчиками из указанного configure.xml
+func NewSMemoryFromFile(confile string) (*SMemory, error) {
+
+	sm := NewSMemory()
+	if err := sm.LoadConfig(confile); err != nil {
+		return nil, err
+	}
+
+	return sm, nil
+}
+
+// ----------------------------------------------------------------------------------
+// Задать размер очереди сообщений для вновь создаваемых клиентов
+func (sm *SMemory) SetQueueSize(qsize uint) {
+	sm.mut.Lock()
+	defer sm.mut.Unlock()
+	sm.qsize = qsize
+}
+
+// ----------------------------------------------------------------------------------
+// Создание эмулятора с датчиками из уже загруженной конфигурации
+func NewSMemoryFromConfig(conf *config.Config) (*SMemory, error) {
+
+	sm := NewSMemory()
+	if err := sm.loadSensors(conf); err != nil {
+		return nil, err
+	}
+
+	return sm, nil
+}
+
+// ----------------------------------------------------------------------------------
+// Загрузка датчиков из секции <ObjectsMap><sensors> файла confile
+func (sm *SMemory) LoadConfig(confile string) error {
+
+	conf, err := config.Load(confile)
+	if err != nil {
+		return fmt.Errorf("(SMemory): %s", err)
+	}
+
+	return sm.loadSensors(conf)
+}
+
+// ----------------------------------------------------------------------------------
+func (sm *SMemory) loadSensors(conf *config.Config) error {
+
+	for _, s := range conf.Sensors {
+
+		var defval int64
+		if d := s.Attrs["default"]; len(d) > 0 {
+			var err error
+			defval, err = strconv.ParseInt(d, 10, 64)
+			if err != nil {
+				return fmt.Errorf("(SMemory): bad default='%s' for sensor '%s'", d, s.Name)
+			}
+		}
+
+		sm.AddSensor(ObjectID(s.ID), s.Name, defval)
+	}
+
+	return nil
+}
+
+// ----------------------------------------------------------------------------------
+// Добавить датчик (если такой уже есть, значение будет переписано)
+func (sm *SMemory) AddSensor(sid ObjectID, name string, defval int64) {
+
+	sm.mut.Lock()
+	defer sm.mut.Unlock()
+
+	sm.sensors[sid] = &smSensor{sid, name, defval, time.Now()}
+	if len(name) > 0 {
+		sm.names[name] = sid
+	}
+}
+
+// ----------------------------------------------------------------------------------
+// Получить идентификатор датчика по имени
+// Если датчик не найден, возвращается DefaultObjectID
+func (sm *SMemory) SensorID(name string) ObjectID {
+
+	sm.mut.RLock()
+	defer sm.mut.RUnlock()
+
+	id, found := sm.names[name]
+	if !found {
+		return DefaultObjectID
+	}
+
+	return id
+}
+
+// ----------------------------------------------------------------------------------
+func (sm *SMemory) GetValue(sid ObjectID) (int64, error) {
+
+	sm.mut.RLock()
+	defer sm.mut.RUnlock()
+
+	s, found := sm.sensors[sid]
+	if !found {
+		return 0, errors.New(fmt.Sprintf("(SMemory): sensor %d not found", sid))
+	}
+
+	return s.value, nil
+}
+
+// ----------------------------------------------------------------------------------
+// Выставить значение датчика
+// Если значение изменилось, всем клиентам заказавшим датчик посылается уведомление
+func (sm *SMemory) SetValue(sid ObjectID, value int64, supplier ObjectID) error {
+
+	sm.mut.Lock()
+	defer sm.mut.Unlock()
+
+	s, found := sm.sensors[sid]
+	if !found {
+		return errors.New(fmt.Sprintf("(SMemory): sensor %d not found", sid))
+	}
+
+	if s.value == value {
+		return nil
+	}
+
+	s.value = value
+	s.timestamp = time.Now()
+
+	for c := range sm.clients {
+		c.push(&SensorEvent{Id: s.id, Value: s.value, Timestamp: s.timestamp})
+	}
+
+	return nil
+}
+
+// ----------------------------------------------------------------------------------
+// Создать нового клиента
+func (sm *SMemory) NewBackend() *SMBackend {
+
+	sm.mut.RLock()
+	qsize := sm.qsize
+	sm.mut.RUnlock()
+
+	b := SMBackend{}
+	b.sm = sm
+	b.asked = make(map[ObjectID]bool)
+	b.queue = make(chan *SensorEvent, qsize)
+	return &b
+}
+
+// ----------------------------------------------------------------------------------
+// Запуск клиента
+// (уведомления приходят только по заказанным датчикам, см. AskSensor)
+func (b *SMBackend) Run(pollTimeout uint) error {
+
+	b.mut.Lock()
+	if b.active {
+		b.mut.Unlock()
+		return nil
+	}
+	b.active = true
+	b.done = make(chan struct{})
+	b.mut.Unlock()
+
+	b.sm.mut.Lock()
+	b.sm.clients[b] = true
+	b.sm.mut.Unlock()
+
+	return nil
+}
+
+// ----------------------------------------------------------------------------------
+func (b *SMBackend) Terminate() {
+
+	b.mut.Lock()
+	if !b.active {
+		b.mut.Unlock()
+		return
+	}
+	b.active = false
+	b.mut.Unlock()
+
+	b.sm.mut.Lock()
+	delete(b.sm.clients, b)
+	b.sm.mut.Unlock()
+
+	close(b.done)
+}
+
+// ----------------------------------------------------------------------------------
+func (b *SMBackend) GetValue(sid ObjectID) (int64, error) {
+	return b.sm.GetValue(sid)
+}
+
+// ----------------------------------------------------------------------------------
+func (b *SMBackend) SetValue(sid ObjectID, value int64, supplier ObjectID) error {
+	return b.sm.SetValue(sid, value, supplier)
+}
+
+// ----------------------------------------------------------------------------------
+// Заказ уведомлений об изменении датчика
+func (b *SMBackend) AskSensor(sid ObjectID) error {
+
+	if _, err := b.sm.GetValue(sid); err != nil {
+		return err
+	}
+
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	b.asked[sid] = true
+	return nil
+}
+
+// ----------------------------------------------------------------------------------
+// Отказ от уведомлений об изменении датчика
+func (b *SMBackend) UnaskSensor(sid ObjectID) error {
+
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	delete(b.asked, sid)
+	return nil
+}
+
+// ----------------------------------------------------------------------------------
+func (b *SMBackend) WaitMessage(timeout uint) (*SensorEvent, bool) {
+
+	b.mut.RLock()
+	done := b.done
+	b.mut.RUnlock()
+
+	select {
+	case m := <-b.queue:
+		return m, true
+	default:
+	}
+
+	select {
+	case m := <-b.queue:
+		return m, true
+
+	case <-done:
+
+	case <-time.After(time.Duration(timeout) * time.Millisecond):
+	}
+
+	return nil, false
+}
+
+// ----------------------------------------------------------------------------------
+// Помещение уведомления в очередь (вызывается под блокировкой SMemory)
+// При переполнении очереди сообщение теряется (как и в c++-ой реализации)
+func (b *SMBackend) push(m *SensorEvent) {
+
+	b.mut.RLock()
+	ask := b.active && b.asked[m.Id]
+	b.mut.RUnlock()
+
+	if !ask {
+		return
+	}
+
+	select {
+	case b.queue <- m:
+	default:
+	}
+}
+
+// ----------------------------------------------------------------------------------
@@ -0,0 +1,159 @@
+// Пороги.
+// Объект (UObject) обрабатывающий пороги из секции <thresholds> configure.xml:
+//
+//	<thresholds name="thresholds">
+//		<sensor name="AI20_S">
+//			<threshold name="t1" id="1" lowlimit="30" hilimit="40" sid="Threshold1_S" inverse="0"/>
+//		</sensor>
+//	</thresholds>
+//
+// Заказывает аналоговые датчики через UProxy и выставляет связанные дискретные датчики:
+// при value >= hilimit порог срабатывает (1), при value <= lowlimit - сбрасывается (0),
+// между ними состояние не меняется (гистерезис). inverse="1" инвертирует выход.
+// ---------
+// Пример:
+//
+//	th, err := uniset.NewThresholds(id, uniset.DefaultConfig())
+//	uproxy.Add(th)
+//	go th.Run()
+//
+// ---------
+package uniset
+
+import (
+	"fmt"
+	"uniset/config"
+)
+
+// ----------------------------------------------------------------------------------
+type Thresholds struct {
+	id       ObjectID
+	events   chan UMessage
+	commands chan UMessage
+	tmap     map[ObjectID][]*threshold // пороги по аналоговым датчикам
+}
+
+// ----------------------------------------------------------------------------------
+type threshold struct {
+	info  *config.Threshold
+	out   ObjectID
+	state bool
+	init  bool // было ли уже выставлено значение выхода
+}
+
+// ----------------------------------------------------------------------------------
+// Создание объекта обработки порогов
+// id - идентификатор объекта (для регистрации в UProxy)
+func NewThresholds(id ObjectID, conf *config.Config) (*Thresholds, error) {
+
+	if conf == nil {
+		return nil, fmt.Errorf("(Thresholds): configuration not defined")
+	}
+
+	th := Thresholds{}
+	th.id = id
+	th.tmap = make(map[ObjectID][]*threshold)
+
+	for _, t := range conf.Thresholds {
+
+		sid := ObjectID(conf.SensorID(t.Sensor))
+		if sid == DefaultObjectID {
+			return nil, fmt.Errorf("(Thresholds): threshold '%s': unknown sensor '%s'", t.Name, t.Sensor)
+		}
+
+		out := ObjectID(conf.SensorID(t.SID))
+		if out == DefaultObjectID {
+			return nil, fmt.Errorf("(Thresholds): threshold '%s': unknown sensor sid='%s'", t.Name, t.SID)
+		}
+
+		if t.LowLimit > t.HiLimit {
+			return nil, fmt.Errorf("(Thresholds): threshold '%s': lowlimit=%d > hilimit=%d", t.Name, t.LowLimit, t.HiLimit)
+		}
+
+		if si, err := SensorInfoFromConfig(conf, sid); err == nil && si.IOType.IsDiscrete() {
+			return nil, fmt.Errorf("(Thresholds): threshold '%s': sensor '%s' must be analog (iotype=%s)", t.Name, t.Sensor, si.IOType)
+		}
+
+		if si, err := SensorInfoFromConfig(conf, out); err == nil && !si.IOType.IsDiscrete() {
+			return nil, fmt.Errorf("(Thresholds): threshold '%s': sensor '%s' must be discrete (iotype=%s)", t.Name, t.SID, si.IOType)
+		}
+
+		th.tmap[sid] = append(th.tmap[sid], &threshold{info: t, out: out})
+	}
+
+	// на каждый датчик: заказ + выставление выходов
+	qsize := 10 + 2*len(conf.Thresholds)
+	th.events = make(chan UMessage, qsize)
+	th.commands = make(chan UMessage, qsize)
+
+	return &th, nil
+}
+
+// ----------------------------------------------------------------------------------
+func (th *Thresholds) ID() ObjectID {
+	return th.id
+}
+
+// ----------------------------------------------------------------------------------
+func (th *Thresholds) UEvent() chan<- UMessage {
+	return th.events
+}
+
+// ----------------------------------------------------------------------------------
+func (th *Thresholds) UCommand() <-chan UMessage {
+	return th.commands
+}
+
+// ----------------------------------------------------------------------------------
+// Обработка событий (до получения FinishEvent или закрытия канала)
+func (th *Thresholds) Run() {
+
+	for umsg := range th.events {
+
+		if _, ok := umsg.PopAsActivateEvent(); ok {
+			for sid := range th.tmap {
+				AskSensor(th.commands, sid)
+			}
+			continue
+		}
+
+		if sm, ok := umsg.PopAsSensorEvent(); ok {
+			th.doSensorEvent(sm)
+			continue
+		}
+
+		if _, ok := umsg.PopAsFinishEvent(); ok {
+			return
+		}
+	}
+}
+
+// ----------------------------------------------------------------------------------
+func (th *Thresholds) doSensorEvent(sm *SensorEvent) {
+
+	for _, t := range th.tmap[sm.Id] {
+
+		state := t.state
+		if sm.Value >= t.info.HiLimit {
+			state = true
+		} else if sm.Value <= t.info.LowLimit {
+			state = false
+		}
+
+		if t.init && state == t.state {
+			continue
+		}
+
+		t.state = state
+		t.init = true
+
+		out := state != t.info.Inverse
+		if out {
+			SetValue(th.commands, t.out, 1)
+		} else {
+			SetValue(th.commands, t.out, 0)
+		}
+	}
+}
+
+// ----------------------------------------------------------------------------------
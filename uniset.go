@@ -14,6 +14,7 @@
 // После чего UProxy забирает у него сообщения об изменении датчиков (в отдельной go-рутине)
 // и через go-каналы рассылает заказчикам.
 // Для взаимодействия с c++ объектом используется uniset_internal_api входящий в состав uniset
+// (обёрнутый в интерфейс Backend, см. backend.go, что позволяет подключать и другие реализации)
 //
 // В общем случае, достаточно одного UProxy объекта на программу. Но тем не менее он не сделан singleton-ом
 // чтобы не ограничивать возможности
@@ -24,7 +25,8 @@
 // uniset.UInterace() и UObject создавать не нужно.
 // -------------------
 // Т.к. основная работа UObject-ов это работа с датчиками, то для того, чтобы проще было писать объекты
-// реализующие нужную логику, создан генератор кода uniset2-codegen-go.
+// реализующие нужную логику, создан генератор кода uniset-codegen-go (см. cmd/uniset-codegen-go,
+// можно использовать через go generate).
 // Его суть заключается в том, что в специальном xml-файле описываются входы и выходы объекта, по которым
 // генерируется базовая go-структура, которую достаточно просто встроить к себе в объект (как анонимное поле).
 // При этом объявленные входы и выходы объекта становяться доступны для использования как поля структуры.
@@ -36,12 +38,12 @@
 package uniset
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
 	"strconv"
-	"uniset_internal_api"
+	"time"
+	"uniset/config"
 )
 
 type UConfig struct {
@@ -56,53 +58,47 @@ type UProp struct {
 
 // Простой интерфейс для работы с uniset-датчиками (get/set)
 type UInterface struct {
+	backend Backend
 }
 
 func NewUInterface(confile string, uniset_port int) (*UInterface, error) {
 
-	if !uniset_internal_api.IsUniSetInitOK() {
+	if !isInitOK() {
 		return nil, errors.New("Not uniset init...")
 	}
 
+	return NewUInterfaceWithBackend(DefaultBackend()), nil
+}
+
+// ----------------------------------------------------------------------------------
+// Создание UInterface работающего через указанный backend
+func NewUInterfaceWithBackend(b Backend) *UInterface {
+
 	ui := UInterface{}
+	ui.backend = b
 
-	return &ui, nil
+	return &ui
 }
 
 // ----------------------------------------------------------------------------------
 func (ui *UInterface) SetValue(sid ObjectID, value int64, supplier ObjectID) error {
 
-	err := uniset_internal_api.SetValue(int64(sid), value, int64(supplier))
-
-	if !err.GetOk() {
-		return errors.New(err.GetErr())
-	}
-	return nil
+	return ui.backend.SetValue(sid, value, supplier)
 }
 
 // ----------------------------------------------------------------------------------
 func (ui *UInterface) GetValue(sid ObjectID) (int64, error) {
-	err := uniset_internal_api.GetValue(int64(sid))
-	if !err.GetOk() {
-		return 0, errors.New(err.GetErr())
-	}
 
-	return err.GetValue(), nil
+	return ui.backend.GetValue(sid)
 }
 
 // ----------------------------------------------------------------------------------
 // глобальная инициализация
 func Init(confile string) {
 
-	cmdline := uniset_internal_api.ParamsInst()
-
-	for _, p := range os.Args {
-		cmdline.Add_str(p)
-	}
-
-	err := uniset_internal_api.Uniset_init_params(cmdline, confile)
-	if !err.GetOk() {
-		panic(err.GetErr())
+	err := initialize(confile)
+	if err != nil {
+		panic(err.Error())
 	}
 }
 
@@ -113,6 +109,13 @@ func AskSensor(ch chan<- UMessage, sid ObjectID) {
 	ch <- UMessage{&AskCommand{sid, false}}
 }
 
+// ----------------------------------------------------------------------------------
+// обобщённая вспомогательная функция - обёртка для отказа от заказа датчика
+func UnaskSensor(ch chan<- UMessage, sid ObjectID) {
+
+	ch <- UMessage{&UnaskCommand{sid, false}}
+}
+
 // ----------------------------------------------------------------------------------
 // обобщённая вспомогательная функция - обёртка для выставления значения
 func SetValue(ch chan<- UMessage, sid ObjectID, value int64) {
@@ -123,12 +126,13 @@ func SetValue(ch chan<- UMessage, sid ObjectID, value int64) {
 // ----------------------------------------------------------------------------------
 // обобщённая вспомогательная функция
 // Обновление значений по SensorEvent
-func DoUpdateInputs(inputs *[]*Int64Value, sm *SensorEvent) {
+// (с учётом калибровки, см. Int64Value.SetCalibration)
+// inputs - список входов одного типа (например []*Int64Value) или смешанный []Binding
+func DoUpdateInputs[T Binding](inputs *[]T, sm *SensorEvent) {
 
 	for _, s := range *inputs {
-		if *s.Sid == sm.Id {
-			*s.Val = sm.Value
-			s.prev = sm.Value
+		if s.SensorID() == sm.Id {
+			s.SetFromSM(sm.Value, sm.Timestamp)
 		}
 	}
 }
@@ -138,15 +142,15 @@ func DoUpdateInputs(inputs *[]*Int64Value, sm *SensorEvent) {
 // обновление выходов в SM
 // Проходим по списку и если значение поменялось, относительно предыдущего
 // обновляем в SM (SetValue)
-func DoUpdateOutputs(outs *[]*Int64Value, cmdchannel chan<- UMessage) {
+func DoUpdateOutputs[T Binding](outs *[]T, cmdchannel chan<- UMessage) {
 
 	for _, s := range *outs {
-		if s.prev != *s.Val {
+		if s.Changed() {
 
-			SetValue(cmdchannel, *s.Sid, *s.Val)
+			SetValue(cmdchannel, s.SensorID(), s.ValueForSM())
 			// возможно обновлять prev, стоит после подтверждения от UProxy
 			// но пока для простосты обновляем сразу
-			s.prev = *s.Val
+			s.Commit()
 		}
 	}
 }
@@ -154,104 +158,133 @@ func DoUpdateOutputs(outs *[]*Int64Value, cmdchannel chan<- UMessage) {
 // ----------------------------------------------------------------------------------
 // обобщённая вспомогательная функция
 // заказ датчиков (входов)
-func DoAskSensors(inputs *[]*Int64Value, cmdchannel chan<- UMessage) {
+func DoAskSensors[T Binding](inputs *[]T, cmdchannel chan<- UMessage) {
 
 	for _, s := range *inputs {
-		AskSensor(cmdchannel, *s.Sid)
+		AskSensor(cmdchannel, s.SensorID())
 	}
 }
 
 // ----------------------------------------------------------------------------------
 // обобщённая вспомогательная функция
-// чтение входов из SM
-func DoReadInputs(inputs *[]*Int64Value) {
+// отказ от заказа датчиков (входов)
+func DoUnaskSensors[T Binding](inputs *[]T, cmdchannel chan<- UMessage) {
 
 	for _, s := range *inputs {
+		UnaskSensor(cmdchannel, s.SensorID())
+	}
+}
 
-		ret := uniset_internal_api.GetValue(int64(*s.Sid))
+// ----------------------------------------------------------------------------------
+// Источник текущих значений датчиков (Backend, UProxy)
+type ValueGetter interface {
+	GetValue(sid ObjectID) (int64, error)
+}
+
+// ----------------------------------------------------------------------------------
+// обобщённая вспомогательная функция
+// чтение входов из SM (через backend по умолчанию)
+// Для объектов работающих через UProxy см. DoReadInputsFrom
+func DoReadInputs[T Binding](inputs *[]T) {
+
+	DoReadInputsFrom(DefaultBackend(), inputs)
+}
+
+// ----------------------------------------------------------------------------------
+// обобщённая вспомогательная функция
+// чтение входов из SM через указанный источник
+// (обычно UProxy к которому добавлен объект, тогда чтение идёт через его backend)
+func DoReadInputsFrom[T Binding](src ValueGetter, inputs *[]T) {
+
+	for _, s := range *inputs {
 
-		if ret.GetOk() {
-			*s.Val = ret.GetValue()
-			s.prev = *s.Val
+		val, err := src.GetValue(s.SensorID())
+
+		if err == nil {
+			s.SetFromSM(val, time.Now())
 		}
 	}
 }
 
 // ----------------------------------------------------------------------------------
 // получить аргумент из командной строки
+// (при отсутствии значения у аргумента - panic, см. ReadArgParam)
 func GetArgParam(param string, defval string) string {
 
+	val, err := ReadArgParam(param, defval)
+	if err != nil {
+		panic(fmt.Sprintf("(uniset.GetArgParam): error: %s", err))
+	}
+
+	return val
+}
+
+// ----------------------------------------------------------------------------------
+// получить аргумент из командной строки (вариант возвращающий ошибку)
+func ReadArgParam(param string, defval string) (string, error) {
+
 	argc := len(os.Args)
 
 	for i := 0; i < argc; i++ {
 
 		if os.Args[i] == param {
 			if (i + 1) < argc {
-				return os.Args[i+1]
+				return os.Args[i+1], nil
 			}
-			panic(fmt.Sprintf("(uniset.GetArgParam): error: required argument for %s", param))
+			return defval, errors.New(fmt.Sprintf("required argument for %s", param))
 		}
 	}
 
-	return defval
+	return defval, nil
 }
 
 // ----------------------------------------------------------------------------------
 // функция получения значения для указанного свойства.
-// Выбор делается по приоритету:
+// Выбор делается по приоритету (см. PropSources):
 // если задан аргумент в командной строке, то выбирается он
-// если нет, смотрим config, если там тоже нет, то возвращаем defval
+// если нет, смотрим переменные окружения, затем config, если там тоже нет, то возвращаем defval
 // При этом в командной строке ищется значение --name-propname
-//
+// а в окружении UNISET_NAME_PROPNAME
 func PropValueByName(cfg *UConfig, propname string, defval string) string {
 
-	if len(propname) == 0 {
-		return defval
+	val, err := ReadPropValue(cfg, propname, defval)
+	if err != nil {
+		panic(fmt.Sprintf("(uniset.PropValueByName): error: %s", err))
 	}
 
-	p := GetArgParam(fmt.Sprintf("--%s-%s", cfg.Name, propname), "")
-
-	if len(p) != 0 {
-		return p
-	}
+	return val
+}
 
-	for _, v := range cfg.Config {
+// ----------------------------------------------------------------------------------
+// получение значения свойства (см. PropValueByName) с возвратом ошибки
+// Прочитанное свойство регистрируется (см. RegisterProp, CheckUnknownProps)
+func ReadPropValue(cfg *UConfig, propname string, defval string) (string, error) {
 
-		if v.Prop == propname {
-			return v.Value
-		}
+	if len(propname) == 0 {
+		return defval, nil
 	}
 
-	return defval
+	RegisterProp(cfg.Name, PropInfo{Name: propname, Default: defval})
+	return lookupProp(cfg, propname, defval)
 }
 
 // ----------------------------------------------------------------------------------
 func InitInt32(cfg *UConfig, propname string, defval string) int32 {
 
-	sval := PropValueByName(cfg, propname, defval)
-	if len(sval) == 0 {
-		return 0
-	}
-
-	i, err := strconv.ParseInt(sval, 10, 32)
+	i, err := ReadInt32(cfg, propname, defval)
 	if err != nil {
-		panic(fmt.Sprintf("(uniset.InitInt32): convert type '%s' error: %s", propname, err))
+		panic(fmt.Sprintf("(uniset.InitInt32): %s", err))
 	}
 
-	return int32(i)
+	return i
 }
 
 // ----------------------------------------------------------------------------------
 func InitInt64(cfg *UConfig, propname string, defval string) int64 {
 
-	sval := PropValueByName(cfg, propname, defval)
-	if len(sval) == 0 {
-		return 0
-	}
-
-	i, err := strconv.ParseInt(sval, 10, 64)
+	i, err := ReadInt64(cfg, propname, defval)
 	if err != nil {
-		panic(fmt.Sprintf("(uniset.InitInt64): convert type '%s' error: %s", propname, err))
+		panic(fmt.Sprintf("(uniset.InitInt64): %s", err))
 	}
 
 	return i
@@ -260,48 +293,34 @@ func InitInt64(cfg *UConfig, propname string, defval string) int64 {
 // ----------------------------------------------------------------------------------
 func InitFloat32(cfg *UConfig, propname string, defval string) float32 {
 
-	sval := PropValueByName(cfg, propname, defval)
-	if len(sval) == 0 {
-		return 0.0
-	}
-	i, err := strconv.ParseFloat(sval, 32)
+	f, err := ReadFloat32(cfg, propname, defval)
 	if err != nil {
-		panic(fmt.Sprintf("(uniset.InitFloat32): convert type '%s' error: %s", propname, err))
+		panic(fmt.Sprintf("(uniset.InitFloat32): %s", err))
 	}
 
-	return float32(i)
+	return f
 }
 
 // ----------------------------------------------------------------------------------
 func InitFloat64(cfg *UConfig, propname string, defval string) float64 {
 
-	sval := PropValueByName(cfg, propname, defval)
-	if len(sval) == 0 {
-		return 0.0
-	}
-
-	i, err := strconv.ParseFloat(sval, 64)
+	f, err := ReadFloat64(cfg, propname, defval)
 	if err != nil {
-		panic(fmt.Sprintf("(uniset.InitFloat64): convert type '%s' error: %s", propname, err))
+		panic(fmt.Sprintf("(uniset.InitFloat64): %s", err))
 	}
 
-	return i
+	return f
 }
 
 // ----------------------------------------------------------------------------------
 func InitBool(cfg *UConfig, propname string, defval string) bool {
 
-	sval := PropValueByName(cfg, propname, defval)
-	if len(sval) == 0 {
-		return false
-	}
-
-	i, err := strconv.ParseBool(sval)
+	b, err := ReadBool(cfg, propname, defval)
 	if err != nil {
-		panic(fmt.Sprintf("(uniset.InitBool): convert type '%s' error: %s", propname, err))
+		panic(fmt.Sprintf("(uniset.InitBool): %s", err))
 	}
 
-	return i
+	return b
 }
 
 // ----------------------------------------------------------------------------------
@@ -311,34 +330,168 @@ func InitString(cfg *UConfig, propname string, defval string) string {
 }
 
 // ----------------------------------------------------------------------------------
+// Для неизвестного имени возвращается идентификатор полученный от c++-части
+// (как правило DefaultObjectID), см. ReadSensorID
 func InitSensorID(cfg *UConfig, propname string, defval string) ObjectID {
 
-	//fmt.Printf("init sensorID %s ret=%d\n",propname,uniset_internal_api.GetObjectID(PropValueByName(cfg,propname)))
-	return ObjectID(uniset_internal_api.GetSensorID(PropValueByName(cfg, propname, defval)))
+	return getSensorID(PropValueByName(cfg, propname, defval))
 }
 
 // ----------------------------------------------------------------------------------
 func InitObjectID(cfg *UConfig, propname string, defval string) ObjectID {
 
-	return ObjectID(uniset_internal_api.GetObjectID(PropValueByName(cfg, propname, defval)))
+	return getObjectID(PropValueByName(cfg, propname, defval))
+}
+
+// ----------------------------------------------------------------------------------
+// Варианты Init-функций возвращающие ошибку вместо panic
+// (для сбора всех ошибок сразу см. ConfigReader)
+// Пустое значение свойства не является ошибкой (возвращается нулевое значение)
+func ReadInt32(cfg *UConfig, propname string, defval string) (int32, error) {
+
+	i, err := readInt(cfg, propname, defval, 32)
+	return int32(i), err
+}
+
+// ----------------------------------------------------------------------------------
+func ReadInt64(cfg *UConfig, propname string, defval string) (int64, error) {
+
+	return readInt(cfg, propname, defval, 64)
+}
+
+// ----------------------------------------------------------------------------------
+func ReadFloat32(cfg *UConfig, propname string, defval string) (float32, error) {
+
+	f, err := readFloat(cfg, propname, defval, 32)
+	return float32(f), err
+}
+
+// ----------------------------------------------------------------------------------
+func ReadFloat64(cfg *UConfig, propname string, defval string) (float64, error) {
+
+	return readFloat(cfg, propname, defval, 64)
+}
+
+// ----------------------------------------------------------------------------------
+func ReadBool(cfg *UConfig, propname string, defval string) (bool, error) {
+
+	sval, err := ReadPropValue(cfg, propname, defval)
+	if err != nil || len(sval) == 0 {
+		return false, err
+	}
+
+	b, err := strconv.ParseBool(sval)
+	if err != nil {
+		return false, convertError(propname, sval, err)
+	}
+
+	return b, nil
+}
+
+// ----------------------------------------------------------------------------------
+func ReadString(cfg *UConfig, propname string, defval string) (string, error) {
+
+	return ReadPropValue(cfg, propname, defval)
+}
+
+// ----------------------------------------------------------------------------------
+// Если значение свойства задано, но такого датчика нет - ошибка
+// Если значение не задано - возвращается DefaultObjectID
+func ReadSensorID(cfg *UConfig, propname string, defval string) (ObjectID, error) {
+
+	name, err := ReadPropValue(cfg, propname, defval)
+	if err != nil || len(name) == 0 {
+		return DefaultObjectID, err
+	}
+
+	sid := getSensorID(name)
+	if sid == DefaultObjectID {
+		return sid, errors.New(fmt.Sprintf("property '%s': unknown sensor '%s'", propname, name))
+	}
+
+	return sid, nil
+}
+
+// ----------------------------------------------------------------------------------
+// аналогично ReadSensorID, но для объектов
+func ReadObjectID(cfg *UConfig, propname string, defval string) (ObjectID, error) {
+
+	name, err := ReadPropValue(cfg, propname, defval)
+	if err != nil || len(name) == 0 {
+		return DefaultObjectID, err
+	}
+
+	id := getObjectID(name)
+	if id == DefaultObjectID {
+		return id, errors.New(fmt.Sprintf("property '%s': unknown object '%s'", propname, name))
+	}
+
+	return id, nil
+}
+
+// ----------------------------------------------------------------------------------
+func readInt(cfg *UConfig, propname string, defval string, bitSize int) (int64, error) {
+
+	sval, err := ReadPropValue(cfg, propname, defval)
+	if err != nil || len(sval) == 0 {
+		return 0, err
+	}
+
+	i, err := strconv.ParseInt(sval, 10, bitSize)
+	if err != nil {
+		return 0, convertError(propname, sval, err)
+	}
+
+	return i, nil
+}
+
+// ----------------------------------------------------------------------------------
+func readFloat(cfg *UConfig, propname string, defval string, bitSize int) (float64, error) {
+
+	sval, err := ReadPropValue(cfg, propname, defval)
+	if err != nil || len(sval) == 0 {
+		return 0.0, err
+	}
+
+	f, err := strconv.ParseFloat(sval, bitSize)
+	if err != nil {
+		return 0.0, convertError(propname, sval, err)
+	}
+
+	return f, nil
+}
+
+// ----------------------------------------------------------------------------------
+func convertError(propname string, sval string, err error) error {
+
+	if ne, ok := err.(*strconv.NumError); ok {
+		err = ne.Err
+	}
+
+	return errors.New(fmt.Sprintf("convert type '%s' error: bad value '%s' (%s)", propname, sval, err))
 }
 
 // ----------------------------------------------------------------------------------
 func GetConfigParamsByName(name string, section string) (*UConfig, error) {
 
-	jstr := uniset_internal_api.GetConfigParamsByName(name, section)
+	return getConfigParams(name, section)
+}
+
+// ----------------------------------------------------------------------------------
+// Получение настроек объекта из загруженной (см. пакет config) конфигурации
+// (аналог GetConfigParamsByName не требующий c++-части)
+func GetConfigParamsFromXML(conf *config.Config, name string, section string) (*UConfig, error) {
 
-	if len(jstr) == 0 {
-		return nil, errors.New(fmt.Sprintf("(GetConfigParamsByName): Not found config section <%s name='%s'..> read error", section, name))
+	node := conf.FindNode(name, section)
+	if node == nil {
+		return nil, errors.New(fmt.Sprintf("(GetConfigParamsFromXML): Not found config section <%s name='%s'..>", section, name))
 	}
 
 	cfg := UConfig{}
 	cfg.Name = name
-	bytes := []byte(jstr)
 
-	err := json.Unmarshal(bytes, &cfg)
-	if err != nil {
-		return nil, errors.New(fmt.Sprintf("(GetConfigParamsByName): error: %s", err))
+	for _, a := range node.Attrs {
+		cfg.Config = append(cfg.Config, UProp{a.Name, a.Value})
 	}
 
 	return &cfg, nil
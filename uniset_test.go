@@ -1,12 +1,20 @@
-// \todo Продумать как тестировать то, что требует запуск SM
+// Тесты требующие SM используют эмулятор (см. uniset.SMemory)
 
 package uniset_test
 
 import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"testing"
 	"time"
 	"uniset"
+	"uniset/config"
 )
 
 // -----------------------------------------------------------------------------
@@ -197,18 +205,79 @@ func (c *TestObject) read(t *testing.T, sid uniset.ObjectID, timeout_msec int, w
 	return num
 }
 
+// ----------------------------------------------------------------
+func newTestSM(t *testing.T) *uniset.SMemory {
+
+	sm, err := uniset.NewSMemoryFromFile("configure.xml")
+	if err != nil {
+		t.Fatalf("SMemory: load error: %s", err)
+	}
+
+	return sm
+}
+
+// ----------------------------------------------------------------
+func newTestUProxy(sm *uniset.SMemory, name string) *uniset.UProxy {
+	return uniset.NewUProxyWithBackend(name, sm.NewBackend(), 2000, 20, 100, 200)
+}
+
+// ----------------------------------------------------------------
+// Тест эмулятора SM: значения по умолчанию и уведомления
+// ----------------------------------------------------------------
+func TestSMemory(t *testing.T) {
+
+	sm := newTestSM(t)
+
+	if sid := sm.SensorID("AI20_S"); sid != 20 {
+		t.Errorf("SMemory: SensorID('AI20_S')=%d != 20", sid)
+	}
+
+	val, err := sm.GetValue(1)
+	if err != nil || val != 1 {
+		t.Errorf("SMemory: default value for Input1_S: %d err: %v", val, err)
+	}
+
+	if _, err = sm.GetValue(12345); err == nil {
+		t.Error("SMemory: GetValue for unknown sensor must fail")
+	}
+
+	b := sm.NewBackend()
+	b.Run(200)
+	defer b.Terminate()
+
+	if err = b.AskSensor(20); err != nil {
+		t.Fatalf("SMemory: AskSensor error: %s", err)
+	}
+
+	// не заказанный датчик
+	sm.SetValue(1, 0, uniset.DefaultObjectID)
+
+	sm.SetValue(20, 42, uniset.DefaultObjectID)
+	sm.SetValue(20, 42, uniset.DefaultObjectID)
+
+	m, ok := b.WaitMessage(100)
+	if !ok {
+		t.Fatal("SMemory: no SensorEvent after SetValue")
+	}
+
+	if m.Id != 20 || m.Value != 42 {
+		t.Errorf("SMemory: bad SensorEvent: %s", m)
+	}
+
+	if _, ok = b.WaitMessage(50); ok {
+		t.Error("SMemory: SensorEvent without change value")
+	}
+}
+
 // ----------------------------------------------------------------
 // Тест получения значения датчика
 // ----------------------------------------------------------------
-/*
 func TestGetValue(t *testing.T) {
 
-	ui, err := uniset.NewUInterface("configure.xml", 53817)
-	if err != nil {
-		t.Error("UInterface: error: %s", err)
-	}
+	sm := newTestSM(t)
+	ui := uniset.NewUInterfaceWithBackend(sm.NewBackend())
 
-	uproxy := uniset.NewUProxy("UProxy1", "configure.xml", 53817)
+	uproxy := newTestUProxy(sm, "UProxy1")
 
 	defer uproxy.Terminate()
 	uproxy.Run()
@@ -222,7 +291,7 @@ func TestGetValue(t *testing.T) {
 	val, err := uproxy.GetValue(20)
 
 	if err != nil {
-		t.Error("UProxy: GetValue error: %s", err)
+		t.Errorf("UProxy: GetValue error: %s", err)
 	}
 
 	if err == nil && val != 20 {
@@ -230,7 +299,7 @@ func TestGetValue(t *testing.T) {
 	}
 
 }
-*/
+
 // ----------------------------------------------------------------
 // Тест заказа датчика (многопоточный заказ)
 // ----------------------------------------------------------------
@@ -255,15 +324,11 @@ func doReadSensorEvents(t *testing.T, timeout_msec time.Duration, list []*TestOb
 // ----------------------------------------------------------------
 // Штатная работа UProxy-а
 // ----------------------------------------------------------------
-/*
 func TestUWorking(t *testing.T) {
 
-	uproxy := uniset.NewUProxy("UProxy2", "configure.xml", 53817)
-	ui, err := uniset.NewUInterface("configure.xml", 53817)
-
-	if err != nil {
-		t.Error("UInterface: error: %s", err)
-	}
+	sm := newTestSM(t)
+	uproxy := newTestUProxy(sm, "UProxy2")
+	ui := uniset.NewUInterfaceWithBackend(sm.NewBackend())
 
 	maxNum := 4
 
@@ -271,7 +336,7 @@ func TestUWorking(t *testing.T) {
 
 	defer uproxy.Terminate()
 
-	err = uproxy.Run()
+	err := uproxy.Run()
 	if err != nil {
 		t.Errorf("UProxy: Run error: %s", err.Error())
 	}
@@ -285,14 +350,15 @@ func TestUWorking(t *testing.T) {
 		uproxy.Add(c)
 	}
 
-	time.Sleep(2 * time.Second)
-
 	var wg sync.WaitGroup
-	wg.Add(2)
+	wg.Add(1)
 
 	var sid uniset.ObjectID = 20
 
-	go doAskSensors(t, sid, clist[0:maxNum], &wg)
+	doAskSensors(t, sid, clist[0:maxNum], &wg)
+
+	// даём время на обработку заказа
+	time.Sleep(300 * time.Millisecond)
 
 	msgCount := 3
 	for i := 0; i < msgCount; i++ {
@@ -302,6 +368,7 @@ func TestUWorking(t *testing.T) {
 		}
 	}
 
+	wg.Add(1)
 	go doReadSensorEvents(t, 300, clist[0:maxNum], &wg)
 
 	wg.Wait()
@@ -316,4 +383,2177 @@ func TestUWorking(t *testing.T) {
 		}
 	}
 }
-*/
+
+// ----------------------------------------------------------------
+// Короткий импульс на DI-датчике не должен теряться
+// ----------------------------------------------------------------
+func TestAskSensorPulse(t *testing.T) {
+
+	sm := newTestSM(t)
+	uproxy := newTestUProxy(sm, "UProxy1")
+
+	defer uproxy.Terminate()
+	uproxy.Run()
+
+	obj := makeUObjects(100, 1)[0]
+	uproxy.Add(obj)
+	obj.AskSensor(1)
+
+	// ждём ответ на заказ (текущее значение)
+	time.Sleep(300 * time.Millisecond)
+
+	sm.SetValue(1, 0, uniset.DefaultObjectID)
+	sm.SetValue(1, 1, uniset.DefaultObjectID)
+	sm.SetValue(1, 0, uniset.DefaultObjectID)
+
+	var values []int64
+	timeout := time.After(500 * time.Millisecond)
+
+	for len(values) < 4 {
+		select {
+		case umsg := <-obj.rchannel:
+			if sm, ok := umsg.PopAsSensorEvent(); ok {
+				values = append(values, sm.Value)
+			}
+		case <-timeout:
+			t.Fatalf("Pulse: timeout. received values: %v", values)
+		}
+	}
+
+	expected := []int64{1, 0, 1, 0}
+	for i, v := range expected {
+		if values[i] != v {
+			t.Errorf("Pulse: values %v != %v", values, expected)
+			break
+		}
+	}
+}
+
+// ----------------------------------------------------------------
+// Отказ от заказа датчика
+// ----------------------------------------------------------------
+func TestUnaskSensor(t *testing.T) {
+
+	sm := newTestSM(t)
+	uproxy := newTestUProxy(sm, "UProxy1")
+
+	defer uproxy.Terminate()
+	uproxy.Run()
+
+	clist := makeUObjects(100, 2)
+	for _, c := range clist {
+		uproxy.Add(c)
+		c.AskSensor(20)
+	}
+
+	time.Sleep(300 * time.Millisecond)
+	for _, c := range clist {
+		c.ReadEvent(50)
+		c.SensorEventCounter = 0
+	}
+
+	uniset.UnaskSensor(clist[0].wchannel, 20)
+	time.Sleep(300 * time.Millisecond)
+
+	select {
+	case umsg := <-clist[0].rchannel:
+		cmd, ok := umsg.PopAsUnaskCommand()
+		if !ok || !cmd.Result {
+			t.Errorf("Unask: bad reply %v", umsg)
+		}
+	default:
+		t.Error("Unask: no reply")
+	}
+
+	sm.SetValue(20, 100, uniset.DefaultObjectID)
+
+	clist[0].ReadEvent(200)
+	clist[1].ReadEvent(50)
+
+	if clist[0].SensorEventCounter != 0 {
+		t.Errorf("Unask: SensorEventCounter = %d after unask", clist[0].SensorEventCounter)
+	}
+
+	if clist[1].SensorEventCounter != 1 {
+		t.Errorf("Unask: SensorEventCounter = %d != 1", clist[1].SensorEventCounter)
+	}
+
+	uniset.UnaskSensor(clist[1].wchannel, 20)
+	time.Sleep(300 * time.Millisecond)
+
+	sm.SetValue(20, 200, uniset.DefaultObjectID)
+	clist[1].ReadEvent(200)
+
+	if clist[1].SensorEventCounter != 1 {
+		t.Errorf("Unask: SensorEventCounter = %d after unask", clist[1].SensorEventCounter)
+	}
+}
+
+// ----------------------------------------------------------------
+// Удаление объекта из работающего UProxy
+// ----------------------------------------------------------------
+func TestRemoveObject(t *testing.T) {
+
+	sm := newTestSM(t)
+	uproxy := newTestUProxy(sm, "UProxy1")
+
+	defer uproxy.Terminate()
+	uproxy.Run()
+
+	clist := makeUObjects(100, 2)
+	for _, c := range clist {
+		uproxy.Add(c)
+		c.AskSensor(20)
+	}
+
+	time.Sleep(300 * time.Millisecond)
+	for _, c := range clist {
+		c.ReadEvent(50)
+		c.SensorEventCounter = 0
+	}
+
+	uproxy.Remove(clist[0].ID())
+
+	finish := false
+	timeout := time.After(500 * time.Millisecond)
+
+loop:
+	for {
+		select {
+		case umsg, ok := <-clist[0].rchannel:
+			if !ok {
+				break loop
+			}
+			if _, ok = umsg.PopAsFinishEvent(); ok {
+				finish = true
+			}
+		case <-timeout:
+			t.Fatal("Remove: event channel is not closed")
+		}
+	}
+
+	if !finish {
+		t.Error("Remove: FinishEvent not received")
+	}
+
+	sm.SetValue(20, 100, uniset.DefaultObjectID)
+	clist[1].ReadEvent(200)
+
+	if clist[1].SensorEventCounter != 1 {
+		t.Errorf("Remove: SensorEventCounter = %d != 1", clist[1].SensorEventCounter)
+	}
+}
+
+// ----------------------------------------------------------------
+// Завершение работы через context
+// ----------------------------------------------------------------
+func TestRunContext(t *testing.T) {
+
+	sm := newTestSM(t)
+
+	// большой eventTimeout не должен задерживать завершение
+	uproxy := uniset.NewUProxyWithBackend("UProxy1", sm.NewBackend(), 2000, 20, 5000, 200)
+
+	obj := makeUObjects(100, 1)[0]
+	uproxy.Add(obj)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+
+	go func() {
+		result <- uproxy.RunContext(ctx)
+	}()
+
+	time.Sleep(200 * time.Millisecond)
+
+	if !uproxy.IsActive() {
+		t.Error("RunContext: UProxy not active")
+	}
+
+	start := time.Now()
+	cancel()
+
+	select {
+	case err := <-result:
+		if err != context.Canceled {
+			t.Errorf("RunContext: error: %v (must be %s)", err, context.Canceled)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("RunContext: not finished after cancel")
+	}
+
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Errorf("RunContext: finish time %s too long", d)
+	}
+
+	if uproxy.IsActive() {
+		t.Error("RunContext: UProxy still active after cancel")
+	}
+
+	// объект должен получить FinishEvent
+	finish := false
+	for umsg := range obj.rchannel {
+		if _, ok := umsg.PopAsFinishEvent(); ok {
+			finish = true
+		}
+	}
+
+	if !finish {
+		t.Error("RunContext: FinishEvent not received")
+	}
+
+	// при завершении через Terminate ошибки нет
+	uproxy2 := uniset.NewUProxyWithBackend("UProxy1", sm.NewBackend(), 2000, 20, 5000, 200)
+
+	go func() {
+		result <- uproxy2.RunContext(context.Background())
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	uproxy2.Terminate()
+
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Errorf("RunContext: error after Terminate: %s", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("RunContext: not finished after Terminate")
+	}
+}
+
+// ----------------------------------------------------------------
+func TestShutdown(t *testing.T) {
+
+	sm := newTestSM(t)
+	uproxy := uniset.NewUProxyWithBackend("UProxy1", sm.NewBackend(), 2000, 20, 5000, 200)
+
+	if err := uproxy.Run(); err != nil {
+		t.Fatalf("Shutdown: Run error: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := uproxy.Shutdown(ctx); err != nil {
+		t.Errorf("Shutdown: error: %s", err)
+	}
+
+	if uproxy.IsActive() {
+		t.Error("Shutdown: UProxy still active")
+	}
+}
+
+// ----------------------------------------------------------------
+// Повторный запуск после Terminate
+// ----------------------------------------------------------------
+func TestRestart(t *testing.T) {
+
+	sm := newTestSM(t)
+	uproxy := newTestUProxy(sm, "UProxy1")
+
+	for run := 1; run <= 2; run++ {
+
+		if err := uproxy.Run(); err != nil {
+			t.Fatalf("run %d: Run error: %s", run, err)
+		}
+
+		// после завершения объекты удаляются, поэтому каждый раз добавляем заново
+		obj := makeUObjects(100, 1)[0]
+		uproxy.Add(obj)
+		obj.AskSensor(20)
+
+		waitMessage(t, obj, "ask reply", func(umsg *uniset.UMessage) bool {
+			_, ok := umsg.PopAsSensorEvent()
+			return ok
+		})
+
+		v := int64(run * 10)
+		sm.SetValue(20, v, uniset.DefaultObjectID)
+
+		waitMessage(t, obj, fmt.Sprintf("run %d: SensorEvent", run), func(umsg *uniset.UMessage) bool {
+			sm, ok := umsg.PopAsSensorEvent()
+			return ok && sm.Id == 20 && sm.Value == v
+		})
+
+		if err := uproxy.Terminate(); err != nil {
+			t.Fatalf("run %d: Terminate error: %s", run, err)
+		}
+
+		// FinishEvent и закрытие канала
+		finish := false
+		for umsg := range obj.rchannel {
+			if _, ok := umsg.PopAsFinishEvent(); ok {
+				finish = true
+			}
+		}
+
+		if !finish {
+			t.Errorf("run %d: FinishEvent not received", run)
+		}
+	}
+}
+
+// ----------------------------------------------------------------
+// SIGHUP --> ReloadEvent (через пользовательский обработчик)
+// ----------------------------------------------------------------
+func TestSignalCallback(t *testing.T) {
+
+	sm := newTestSM(t)
+	uproxy := newTestUProxy(sm, "UProxy1")
+
+	signals := make(chan os.Signal, 1)
+	uproxy.SetSignalCallback(func(sig os.Signal) {
+		signals <- sig
+		uproxy.Reload()
+	})
+
+	defer uproxy.Terminate()
+	uproxy.Run()
+
+	obj := makeUObjects(100, 1)[0]
+	uproxy.Add(obj)
+
+	// сигнал посылаем только после того как объект добавлен,
+	// иначе ReloadEvent может уйти раньше, чем объект попадёт в UProxy
+	activated := false
+	timeout := time.After(time.Second)
+	for !activated {
+		select {
+		case umsg := <-obj.rchannel:
+			_, activated = umsg.PopAsActivateEvent()
+		case <-timeout:
+			t.Fatal("Signal: ActivateEvent not received")
+		}
+	}
+
+	syscall.Kill(os.Getpid(), syscall.SIGHUP)
+
+	select {
+	case sig := <-signals:
+		if sig != syscall.SIGHUP {
+			t.Errorf("Signal: unexpected signal %s", sig)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Signal: callback not called")
+	}
+
+	timeout = time.After(time.Second)
+	for {
+		select {
+		case umsg := <-obj.rchannel:
+			if _, ok := umsg.PopAsReloadEvent(); ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("Signal: ReloadEvent not received")
+		}
+	}
+}
+
+// ----------------------------------------------------------------
+// Получение настроек объекта без c++-части
+// ----------------------------------------------------------------
+func TestGetConfigParamsFromXML(t *testing.T) {
+
+	conf, err := config.Load("configure.xml")
+	if err != nil {
+		t.Fatalf("config: load error: %s", err)
+	}
+
+	cfg, err := uniset.GetConfigParamsFromXML(conf, "TestProc", "settings")
+	if err != nil {
+		t.Fatalf("GetConfigParamsFromXML: error: %s", err)
+	}
+
+	if v := uniset.PropValueByName(cfg, "sleep_msec", ""); v != "150" {
+		t.Errorf("GetConfigParamsFromXML: sleep_msec='%s' != '150'", v)
+	}
+
+	if _, err = uniset.GetConfigParamsFromXML(conf, "Unknown", "settings"); err == nil {
+		t.Error("GetConfigParamsFromXML: no error for unknown object")
+	}
+}
+
+// ----------------------------------------------------------------
+// Информация о датчиках
+// ----------------------------------------------------------------
+func TestSensorInfo(t *testing.T) {
+
+	conf, err := config.Load("configure.xml")
+	if err != nil {
+		t.Fatalf("config: load error: %s", err)
+	}
+
+	uniset.SetDefaultConfig(conf)
+	defer uniset.SetDefaultConfig(nil)
+
+	si, err := uniset.SensorInfoByName("Input1_S")
+	if err != nil {
+		t.Fatalf("SensorInfoByName: error: %s", err)
+	}
+
+	if si.ID != 1 || si.IOType != uniset.DI || si.Priority != "Medium" || si.Default != 1 || si.TextName != "Команда 1" {
+		t.Errorf("SensorInfoByName: bad info %s", si)
+	}
+
+	si, err = uniset.SensorInfoByID(20)
+	if err != nil || si.Name != "AI20_S" || si.IOType != uniset.AI {
+		t.Errorf("SensorInfoByID: bad info %v err: %v", si, err)
+	}
+
+	if err == nil && si.Attr("units") != "mA" {
+		t.Errorf("SensorInfoByID: custom attribute units='%s' != 'mA'", si.Attr("units"))
+	}
+
+	if _, err = uniset.SensorInfoByID(100); err == nil {
+		t.Error("SensorInfoByID: no error for object (not sensor)")
+	}
+
+	if err = uniset.CheckIOType(1, uniset.DI, uniset.DO); err != nil {
+		t.Errorf("CheckIOType: error: %s", err)
+	}
+
+	if err = uniset.CheckIOType(20, uniset.DI); err == nil {
+		t.Error("CheckIOType: no error for AI sensor")
+	}
+}
+
+// ----------------------------------------------------------------
+// Калибровка
+// ----------------------------------------------------------------
+func TestCalibration(t *testing.T) {
+
+	conf, err := config.Load("configure.xml")
+	if err != nil {
+		t.Fatalf("config: load error: %s", err)
+	}
+
+	uniset.SetDefaultConfig(conf)
+	defer uniset.SetDefaultConfig(nil)
+
+	cal, err := uniset.CalibrationForSensor(22)
+	if err != nil || cal == nil {
+		t.Fatalf("CalibrationForSensor: error: %v", err)
+	}
+
+	if cal.Name() != "testcal" {
+		t.Errorf("Calibration: name '%s' != 'testcal'", cal.Name())
+	}
+
+	values := []struct {
+		raw int64
+		cal int64
+	}{
+		{-1000, -300},
+		{-2000, -300}, // ниже диаграммы
+		{2000, 600},   // выше диаграммы
+		{0, 0},
+		{25, 8}, // 7.5 --> 8
+		{950, 500},
+	}
+
+	for _, v := range values {
+		if c := cal.GetValue(v.raw); c != v.cal {
+			t.Errorf("Calibration: GetValue(%d)=%d != %d", v.raw, c, v.cal)
+		}
+	}
+
+	if r := cal.Raw(7.5); r != 25 {
+		t.Errorf("Calibration: Raw(7.5)=%v != 25", r)
+	}
+
+	if r := cal.GetRawValue(-60); r != -200 {
+		t.Errorf("Calibration: GetRawValue(-60)=%d != -200", r)
+	}
+
+	if r := cal.GetRawValue(1000); r != 1000 {
+		t.Errorf("Calibration: GetRawValue(1000)=%d != 1000", r)
+	}
+
+	if c, err := uniset.CalibrationForSensor(20); err != nil || c != nil {
+		t.Errorf("CalibrationForSensor: calibration for sensor without caldiagram: %v %v", c, err)
+	}
+
+	// использование во входах
+	var sid uniset.ObjectID = 22
+	var val int64
+	inputs := []*uniset.Int64Value{uniset.NewInt64Value(&sid, &val).SetCalibration(cal)}
+
+	uniset.DoUpdateInputs(&inputs, &uniset.SensorEvent{Id: 22, Value: 50})
+
+	if val != 20 {
+		t.Errorf("Calibration: DoUpdateInputs value=%d != 20", val)
+	}
+}
+
+// ----------------------------------------------------------------
+// Пороги
+// ----------------------------------------------------------------
+func TestThresholds(t *testing.T) {
+
+	conf, err := config.Load("configure.xml")
+	if err != nil {
+		t.Fatalf("config: load error: %s", err)
+	}
+
+	sm, err := uniset.NewSMemoryFromConfig(conf)
+	if err != nil {
+		t.Fatalf("SMemory: error: %s", err)
+	}
+
+	if _, err := uniset.NewThresholds(200, nil); err == nil {
+		t.Error("Thresholds: no error for nil config")
+	}
+
+	th, err := uniset.NewThresholds(200, conf)
+	if err != nil {
+		t.Fatalf("Thresholds: error: %s", err)
+	}
+
+	// выход заранее взведён: начальное значение ниже lowlimit должно его сбросить
+	sm.SetValue(21, 1, uniset.DefaultObjectID)
+
+	uproxy := newTestUProxy(sm, "UProxy1")
+	defer uproxy.Terminate()
+	uproxy.Run()
+
+	uproxy.Add(th)
+	go th.Run()
+
+	check := func(ai int64, expected int64) {
+
+		sm.SetValue(20, ai, uniset.DefaultObjectID)
+
+		var val int64
+		for i := 0; i < 20; i++ {
+			time.Sleep(50 * time.Millisecond)
+			val, _ = sm.GetValue(21)
+			if val == expected {
+				return
+			}
+		}
+
+		t.Errorf("Thresholds: AI=%d: threshold=%d != %d", ai, val, expected)
+	}
+
+	check(20, 0)
+	check(45, 1)
+	check(35, 1) // гистерезис
+	check(30, 0)
+	check(39, 0) // гистерезис
+	check(40, 1)
+}
+
+// ----------------------------------------------------------------
+// Смешанный список входов/выходов (bool, int64, float64)
+// ----------------------------------------------------------------
+func TestMixedBindings(t *testing.T) {
+
+	var (
+		diID  uniset.ObjectID = 1
+		aiID  uniset.ObjectID = 20
+		flID  uniset.ObjectID = 22
+		di    bool
+		ai    int64
+		fl    float64
+		cmdch = make(chan uniset.UMessage, 10)
+	)
+
+	inputs := []uniset.Binding{
+		uniset.NewBoolValue(&diID, &di),
+		uniset.NewInt64Value(&aiID, &ai),
+		uniset.NewFloat64Value(&flID, &fl, 2),
+	}
+
+	uniset.DoUpdateInputs(&inputs, &uniset.SensorEvent{Id: 1, Value: 5})
+	uniset.DoUpdateInputs(&inputs, &uniset.SensorEvent{Id: 20, Value: 42})
+	uniset.DoUpdateInputs(&inputs, &uniset.SensorEvent{Id: 22, Value: 1234})
+
+	if !di || ai != 42 || fl != 12.34 {
+		t.Errorf("Bindings: bad inputs di=%v ai=%d fl=%v", di, ai, fl)
+	}
+
+	// после обновления входов выходы не должны считаться изменёнными
+	uniset.DoUpdateOutputs(&inputs, cmdch)
+	if len(cmdch) != 0 {
+		t.Errorf("Bindings: %d SetValue commands without changes", len(cmdch))
+	}
+
+	di = false
+	fl = 0.155
+	uniset.DoUpdateOutputs(&inputs, cmdch)
+
+	expected := map[uniset.ObjectID]int64{1: 0, 22: 16}
+	if len(cmdch) != len(expected) {
+		t.Fatalf("Bindings: %d SetValue commands != %d", len(cmdch), len(expected))
+	}
+
+	for len(cmdch) > 0 {
+		umsg := <-cmdch
+		cmd, ok := umsg.PopAsSetValueCommand()
+		if !ok {
+			t.Fatalf("Bindings: unexpected command %v", umsg)
+		}
+
+		if v, found := expected[cmd.Id]; !found || v != cmd.Value {
+			t.Errorf("Bindings: SetValue(%d, %d) unexpected", cmd.Id, cmd.Value)
+		}
+	}
+
+	// обратная совместимость со списками одного типа
+	ints := []*uniset.Int64Value{uniset.NewInt64Value(&aiID, &ai)}
+	uniset.DoUpdateInputs(&ints, &uniset.SensorEvent{Id: 20, Value: 7})
+	if ai != 7 {
+		t.Errorf("Bindings: []*Int64Value input %d != 7", ai)
+	}
+}
+
+// ----------------------------------------------------------------
+// Float64Value с точностью из configure.xml
+// ----------------------------------------------------------------
+func TestFloat64ValuePrecision(t *testing.T) {
+
+	conf, err := config.Load("configure.xml")
+	if err != nil {
+		t.Fatalf("config: load error: %s", err)
+	}
+
+	uniset.SetDefaultConfig(conf)
+	defer uniset.SetDefaultConfig(nil)
+
+	var sid uniset.ObjectID = 23
+	var val float64
+
+	fv, err := uniset.NewFloat64ValueFromConfig(&sid, &val)
+	if err != nil {
+		t.Fatalf("Float64Value: error: %s", err)
+	}
+
+	if fv.Precision() != 2 {
+		t.Errorf("Float64Value: precision=%d != 2", fv.Precision())
+	}
+
+	fv.SetFromSM(-1505, time.Now())
+	if val != -15.05 {
+		t.Errorf("Float64Value: SetFromSM(-1505) --> %v != -15.05", val)
+	}
+
+	rounds := []struct {
+		val float64
+		sm  int64
+	}{
+		{1.005, 101},
+		{-1.005, -101},
+		{2.675, 268},
+		{0.004, 0},
+		{0.1 + 0.2, 30},
+		{123.455, 12346},
+	}
+
+	for _, r := range rounds {
+		val = r.val
+		if v := fv.ValueForSM(); v != r.sm {
+			t.Errorf("Float64Value: %v --> %d != %d", r.val, v, r.sm)
+		}
+	}
+
+	// изменения меньше точности не считаются изменением
+	val = 10.0
+	fv.Commit()
+
+	val = 10.001
+	if fv.Changed() {
+		t.Error("Float64Value: change less than precision detected as change")
+	}
+
+	val = 10.01
+	if !fv.Changed() {
+		t.Error("Float64Value: change not detected")
+	}
+
+	if _, err = uniset.NewFloat64ValueFromConfig(new(uniset.ObjectID), &val); err == nil {
+		t.Error("Float64Value: no error for unknown sensor")
+	}
+}
+
+// ----------------------------------------------------------------
+// Обобщённое связывание Value[T]
+// ----------------------------------------------------------------
+type testMode int
+
+const (
+	modeOff testMode = iota
+	modeAuto
+	modeManual
+)
+
+func TestGenericValue(t *testing.T) {
+
+	var sid uniset.ObjectID = 20
+	var mode testMode
+	var f32 float32
+
+	mv := uniset.NewValue(&sid, &mode, uniset.NewEnumCodec(modeOff, modeAuto, modeManual))
+
+	tm := time.Now()
+	mv.SetFromSM(2, tm)
+
+	if mode != modeManual || mv.Prev() != modeManual || !mv.Timestamp().Equal(tm) {
+		t.Errorf("Value[enum]: mode=%d prev=%d tm=%v", mode, mv.Prev(), mv.Timestamp())
+	}
+
+	// недопустимое значение
+	mv.SetFromSM(10, tm)
+	if mode != modeOff {
+		t.Errorf("Value[enum]: mode=%d for bad value != %d", mode, modeOff)
+	}
+
+	mode = modeAuto
+	if !mv.Changed() || mv.Prev() != modeOff {
+		t.Error("Value[enum]: change not detected")
+	}
+
+	mv.Commit()
+	if mv.Changed() || mv.Prev() != modeAuto || mv.ValueForSM() != 1 {
+		t.Error("Value[enum]: bad state after commit")
+	}
+
+	fv := uniset.NewValue(&sid, &f32, uniset.NewFloatCodec[float32](1))
+	fv.SetFromSM(125, tm)
+
+	if f32 != 12.5 || fv.Precision() != 1 {
+		t.Errorf("Value[float32]: %v != 12.5", f32)
+	}
+
+	// округление float32 по его собственному кратчайшему представлению
+	c32 := uniset.NewFloatCodec[float32](2)
+	rounds32 := []struct {
+		val float32
+		sm  int64
+	}{
+		{1.005, 101},
+		{-1.005, -101},
+		{2.675, 268},
+		{0.285, 29},
+		{123.455, 12346},
+	}
+
+	for _, r := range rounds32 {
+		if v := c32.Encode(r.val); v != r.sm {
+			t.Errorf("FloatCodec[float32]: %v --> %d != %d", r.val, v, r.sm)
+		}
+	}
+
+	var u8 uint8
+	uv := uniset.NewValue(&sid, &u8, uniset.IntCodec[uint8]{})
+	uv.SetFromSM(200, tm)
+	if u8 != 200 {
+		t.Errorf("Value[uint8]: %d != 200", u8)
+	}
+
+	// синонимы
+	var b bool
+	var bv *uniset.BoolValue = uniset.NewBoolValue(&sid, &b)
+	bv.SetFromSM(3, tm)
+	if !b {
+		t.Error("BoolValue: false for value 3")
+	}
+}
+
+// ----------------------------------------------------------------
+// Связывание по тегам
+// ----------------------------------------------------------------
+type testBindBase struct {
+	Mode testMode `uniset:"in,sensor=AI20_S"`
+}
+
+type testBindExtra struct {
+	Raw  uint16  `uniset:"in,sensor=AI22_S"`
+	Temp float32 `uniset:"out,sensor=AI23_S"`
+}
+
+type testBindObject struct {
+	testBindBase
+	*testBindExtra
+
+	Input1 bool    `uniset:"in,prop=input1"`
+	Level  float64 `uniset:"in,prop=level,default=AI23_S"`
+	Output int32   `uniset:"out,sensor=AI20_S"`
+	Alarm  bool    `uniset:"out,sensor=Threshold1_S"`
+
+	counter int // поле без тега не связывается
+}
+
+func TestBind(t *testing.T) {
+
+	conf, err := config.Load("configure.xml")
+	if err != nil {
+		t.Fatalf("config: load error: %s", err)
+	}
+
+	uniset.SetDefaultConfig(conf)
+	defer uniset.SetDefaultConfig(nil)
+
+	cfg, err := uniset.GetConfigParamsFromXML(conf, "TestProc", "settings")
+	if err != nil {
+		t.Fatalf("Bind: config error: %s", err)
+	}
+
+	obj := testBindObject{testBindExtra: &testBindExtra{}}
+	inputs, outputs, err := uniset.Bind(&obj, cfg)
+	if err != nil {
+		t.Fatalf("Bind: error: %s", err)
+	}
+
+	if len(inputs) != 4 || len(outputs) != 3 {
+		t.Fatalf("Bind: inputs=%d outputs=%d", len(inputs), len(outputs))
+	}
+
+	uniset.DoUpdateInputs(&inputs, &uniset.SensorEvent{Id: 1, Value: 1})
+	uniset.DoUpdateInputs(&inputs, &uniset.SensorEvent{Id: 23, Value: 1234})
+	uniset.DoUpdateInputs(&inputs, &uniset.SensorEvent{Id: 20, Value: 2})
+	uniset.DoUpdateInputs(&inputs, &uniset.SensorEvent{Id: 22, Value: 300})
+
+	if !obj.Input1 || obj.Level != 12.34 || obj.Mode != modeManual || obj.Raw != 300 {
+		t.Errorf("Bind: bad inputs %+v", obj)
+	}
+
+	// после обновления входов выходы не должны считаться изменёнными
+	cmdch := make(chan uniset.UMessage, 10)
+	uniset.DoUpdateOutputs(&outputs, cmdch)
+	if len(cmdch) != 0 {
+		t.Fatalf("Bind: %d SetValue commands without changes", len(cmdch))
+	}
+
+	obj.Output = 100
+	obj.Alarm = true
+	obj.Temp = 1.005 // float32: точность 2 берётся из configure.xml
+	uniset.DoUpdateOutputs(&outputs, cmdch)
+
+	if len(cmdch) != 3 {
+		t.Fatalf("Bind: %d SetValue commands != 3", len(cmdch))
+	}
+
+	// float32 округляется по своему представлению: float32(1.005) --> 101
+	temp := false
+	for len(cmdch) > 0 {
+		umsg := <-cmdch
+		if cmd, ok := umsg.PopAsSetValueCommand(); ok && cmd.Id == 23 {
+			temp = true
+			if cmd.Value != 101 {
+				t.Errorf("Bind: float32 output value %d != 101", cmd.Value)
+			}
+		}
+	}
+
+	if !temp {
+		t.Error("Bind: no SetValue command for float32 output")
+	}
+
+	bad := []interface{}{
+		obj,
+		&struct {
+			F bool `uniset:"in,sensor=Unknown_S"`
+		}{},
+		&struct {
+			F string `uniset:"in,sensor=AI20_S"`
+		}{},
+		&struct {
+			F bool `uniset:"inout,sensor=AI20_S"`
+		}{},
+		&struct {
+			F bool `uniset:"in,prop=unknown_prop"`
+		}{},
+		&struct {
+			*testBindExtra // nil
+		}{},
+	}
+
+	for _, b := range bad {
+		if _, _, err = uniset.Bind(b, cfg); err == nil {
+			t.Errorf("Bind: no error for %T", b)
+		}
+	}
+}
+
+// ----------------------------------------------------------------------------------
+func TestConfigReader(t *testing.T) {
+
+	conf, err := config.Load("configure.xml")
+	if err != nil {
+		t.Fatalf("config: load error: %s", err)
+	}
+
+	uniset.SetDefaultConfig(conf)
+	defer uniset.SetDefaultConfig(nil)
+
+	cfg := &uniset.UConfig{Name: "TestProc", Config: []uniset.UProp{
+		{"sleep_msec", "150"},
+		{"input1", "Input1_S"},
+		{"badint", "12a"},
+		{"badbool", "yes!"},
+		{"badsensor", "UnknownSensor_S"},
+	}}
+
+	if _, err := uniset.ReadInt32(cfg, "badint", ""); err == nil {
+		t.Error("ReadInt32: bad value must be error")
+	}
+
+	if v, err := uniset.ReadInt32(cfg, "sleep_msec", "10"); err != nil || v != 150 {
+		t.Errorf("ReadInt32: %d, %v", v, err)
+	}
+
+	if v, err := uniset.ReadSensorID(cfg, "notexist", ""); err != nil || v != uniset.DefaultObjectID {
+		t.Errorf("ReadSensorID: empty value must be DefaultObjectID without error (%d, %v)", v, err)
+	}
+
+	r := uniset.NewConfigReader(cfg)
+	r.Require("input1", "required1")
+
+	if r.Int32("sleep_msec", "10") != 150 {
+		t.Error("ConfigReader: bad sleep_msec")
+	}
+
+	if r.SensorID("input1", "") != 1 {
+		t.Error("ConfigReader: bad input1")
+	}
+
+	r.Int64("badint", "")
+	r.Bool("badbool", "")
+	r.SensorID("badsensor", "")
+	r.Float64("notexist", "1.5")
+
+	err = r.Err()
+	if err == nil {
+		t.Fatal("ConfigReader: must be errors")
+	}
+
+	cerr, ok := err.(*uniset.ConfigError)
+	if !ok {
+		t.Fatalf("ConfigReader: error type %T != *ConfigError", err)
+	}
+
+	if len(cerr.Errors) != 4 {
+		t.Errorf("ConfigReader: %d errors != 4\n%s", len(cerr.Errors), err)
+	}
+
+	for _, s := range []string{"required1", "badint", "badbool", "UnknownSensor_S"} {
+		if !strings.Contains(err.Error(), s) {
+			t.Errorf("ConfigReader: error list must contain '%s'", s)
+		}
+	}
+
+	if uniset.NewConfigReader(cfg).Err() != nil {
+		t.Error("ConfigReader: without errors Err() must be nil")
+	}
+}
+
+// ----------------------------------------------------------------------------------
+type testParamsBase struct {
+	Input1 uniset.ObjectID `prop:"input1,required"`
+}
+
+type testParams struct {
+	testParamsBase
+	Sleep   time.Duration   `prop:"sleep_msec,min=10,max=10s"`
+	Timeout time.Duration   `prop:"timeout,default=500ms"`
+	Mode    string          `prop:"mode,default=auto,enum=auto|manual|off"`
+	Level   float64         `prop:"level,default=0.5,min=0,max=1"`
+	Count   int32           `prop:"count,default=3"`
+	Enable  bool            `prop:"enable,default=true"`
+	Self    uniset.ObjectID `prop:"name,object"`
+	Unset   int             `prop:"unset"`
+}
+
+func TestLoadConfig(t *testing.T) {
+
+	conf, err := config.Load("configure.xml")
+	if err != nil {
+		t.Fatalf("config: load error: %s", err)
+	}
+
+	uniset.SetDefaultConfig(conf)
+	defer uniset.SetDefaultConfig(nil)
+
+	cfg, err := uniset.GetConfigParamsFromXML(conf, "TestProc", "settings")
+	if err != nil {
+		t.Fatalf("LoadConfig: config error: %s", err)
+	}
+
+	args := os.Args
+	defer func() { os.Args = args }()
+	os.Args = append([]string{args[0]}, "--TestProc-mode", "manual")
+
+	p := testParams{Unset: 42}
+	if err := uniset.LoadConfig(cfg, &p); err != nil {
+		t.Fatalf("LoadConfig: error: %s", err)
+	}
+
+	if p.Input1 != 1 || p.Self != 100 {
+		t.Errorf("LoadConfig: bad ids input1=%d self=%d", p.Input1, p.Self)
+	}
+
+	if p.Sleep != 150*time.Millisecond || p.Timeout != 500*time.Millisecond {
+		t.Errorf("LoadConfig: bad durations sleep=%s timeout=%s", p.Sleep, p.Timeout)
+	}
+
+	if p.Mode != "manual" || p.Level != 0.5 || p.Count != 3 || !p.Enable || p.Unset != 42 {
+		t.Errorf("LoadConfig: bad values %+v", p)
+	}
+
+	bad := uniset.UConfig{Name: "TestProc", Config: []uniset.UProp{
+		{"sleep_msec", "5"},
+		{"mode", "unknown"},
+		{"level", "1.5"},
+		{"count", "x"},
+	}}
+
+	os.Args = args[:1]
+
+	err = uniset.LoadConfig(&bad, &p)
+	if err == nil {
+		t.Fatal("LoadConfig: must be errors")
+	}
+
+	cerr, ok := err.(*uniset.ConfigError)
+	if !ok || len(cerr.Errors) != 5 {
+		t.Errorf("LoadConfig: expected 5 errors, got: %s", err)
+	}
+
+	if err := uniset.LoadConfig(cfg, p); err == nil {
+		t.Error("LoadConfig: non-pointer must be error")
+	}
+}
+
+// ----------------------------------------------------------------------------------
+func TestPropSources(t *testing.T) {
+
+	defer uniset.SetPropSources(uniset.DefaultPropSources()...)
+
+	dir := t.TempDir()
+
+	// пустые значения в файлах (p_xml) не считаются заданными
+	jsonfile := dir + "/override.json"
+	err := os.WriteFile(jsonfile, []byte(`{"TestSrc": {"p_file": "json", "p_num": 12.5, "p_env": "json", "p_xml": ""}}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	yamlfile := dir + "/override.yaml"
+	yaml := "# test\nTestSrc: # object\n  p_file: \"yaml\" # comment\n  p_yaml: 1\n  p_hash: \"a # b\" # comment\n  p_tag: 'c #d'\n  p_xml:\nOther:\n  p_file: other\n"
+	if err = os.WriteFile(yamlfile, []byte(yaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	jsrc, err := uniset.NewFileSource(jsonfile)
+	if err != nil {
+		t.Fatalf("NewFileSource(json) error: %s", err)
+	}
+
+	ysrc, err := uniset.NewFileSource(yamlfile)
+	if err != nil {
+		t.Fatalf("NewFileSource(yaml) error: %s", err)
+	}
+
+	if uniset.EnvPropName("UNISET", "TestSrc", "p_env") != "UNISET_TESTSRC_P_ENV" {
+		t.Errorf("EnvPropName: %s", uniset.EnvPropName("UNISET", "TestSrc", "p_env"))
+	}
+
+	t.Setenv("UNISET_TESTSRC_P_ENV", "env")
+	t.Setenv("UNISET_TESTSRC_P_XML", "") // пустая переменная не считается заданной
+
+	cfg := &uniset.UConfig{Name: "TestSrc", Config: []uniset.UProp{{"p_file", "xml"}, {"p_xml", "xml"}}}
+
+	uniset.SetPropSources(uniset.ArgsSource(), uniset.EnvSource("UNISET"), jsrc, uniset.ConfigSource())
+
+	expected := map[string]string{"p_env": "env", "p_file": "json", "p_num": "12.5", "p_xml": "xml", "p_def": "def"}
+	for p, v := range expected {
+		if val := uniset.PropValueByName(cfg, p, "def"); val != v {
+			t.Errorf("json: %s='%s' != '%s'", p, val, v)
+		}
+	}
+
+	uniset.SetPropSources(ysrc, uniset.ConfigSource())
+
+	expected = map[string]string{"p_env": "def", "p_file": "yaml", "p_yaml": "1", "p_xml": "xml", "p_hash": "a # b", "p_tag": "c #d"}
+	for p, v := range expected {
+		if val := uniset.PropValueByName(cfg, p, "def"); val != v {
+			t.Errorf("yaml: %s='%s' != '%s'", p, val, v)
+		}
+	}
+
+	sources := make(map[string]string)
+	for _, o := range uniset.PropOrigins() {
+		if o.Object == "TestSrc" {
+			sources[o.Prop] = o.Source
+		}
+	}
+
+	if sources["p_file"] != "file:"+yamlfile || sources["p_xml"] != "config" || sources["p_env"] != "default" {
+		t.Errorf("PropOrigins: bad sources %v", sources)
+	}
+
+	var buf strings.Builder
+	uniset.DumpPropOrigins(&buf)
+	if !strings.Contains(buf.String(), "TestSrc.p_xml = 'xml' (config)") {
+		t.Errorf("DumpPropOrigins: %s", buf.String())
+	}
+
+	uniset.ResetPropOrigins()
+	if n := len(uniset.PropOrigins()); n != 0 {
+		t.Errorf("ResetPropOrigins: %d origins left", n)
+	}
+
+	if _, err := uniset.NewFileSource(dir + "/override.txt"); err == nil {
+		t.Error("NewFileSource: unknown format must be error")
+	}
+}
+
+// ----------------------------------------------------------------------------------
+type testHelpParams struct {
+	Sleep time.Duration `prop:"sleep_msec,default=100,min=10" help:"период цикла"`
+	Mode  string        `prop:"mode,default=auto,enum=auto|manual"`
+}
+
+func TestPropsHelp(t *testing.T) {
+
+	// реестр свойств глобальный, иначе повторный запуск теста (-count=N) видит старые свойства
+	uniset.ResetRegisteredProps()
+	defer uniset.ResetRegisteredProps()
+
+	cfg := &uniset.UConfig{Name: "TestHelp", Config: []uniset.UProp{{"sleep_msec", "200"}}}
+
+	args := os.Args
+	defer func() { os.Args = args }()
+	os.Args = args[:1]
+
+	p := testHelpParams{}
+	if err := uniset.LoadConfig(cfg, &p); err != nil {
+		t.Fatalf("LoadConfig: error: %s", err)
+	}
+
+	r := uniset.NewConfigReader(cfg)
+	r.Help("level", "уровень")
+	r.Float64("level", "0.5")
+
+	props := uniset.RegisteredProps("TestHelp")
+	if len(props) != 3 {
+		t.Fatalf("RegisteredProps: %d != 3", len(props))
+	}
+
+	if props[0].Name != "sleep_msec" || props[0].Type != "duration" || props[0].Description != "период цикла" {
+		t.Errorf("RegisteredProps: bad %+v", props[0])
+	}
+
+	if props[2].Name != "level" || props[2].Type != "float64" || props[2].Default != "0.5" || props[2].Description != "уровень" {
+		t.Errorf("RegisteredProps: bad %+v", props[2])
+	}
+
+	var buf strings.Builder
+	uniset.PrintPropsHelp(&buf, cfg)
+	help := buf.String()
+
+	for _, s := range []string{"sleep_msec", "200 (config)", "период цикла", "auto|manual", "level", "уровень"} {
+		if !strings.Contains(help, s) {
+			t.Errorf("PrintPropsHelp: not found '%s' in\n%s", s, help)
+		}
+	}
+
+	if uniset.HelpRequested("TestHelp") {
+		t.Error("HelpRequested: must be false")
+	}
+
+	os.Args = append(args[:1:1], "--TestHelp-mode", "manual", "--TestHelp-sleep_mseq", "10", "--Other-x", "1")
+
+	err := uniset.CheckUnknownProps("TestHelp")
+	if err == nil || !strings.Contains(err.Error(), "--TestHelp-sleep_mseq") || strings.Contains(err.Error(), "--TestHelp-mode") {
+		t.Errorf("CheckUnknownProps: bad result: %v", err)
+	}
+
+	// свойства прочитанные без ConfigReader/LoadConfig тоже известны
+	uniset.PropValueByName(cfg, "plain", "")
+	uniset.InitInt32(cfg, "count", "1")
+
+	os.Args = append(args[:1:1], "--TestHelp-plain", "x", "--TestHelp-count", "2")
+	if err := uniset.HandlePropArgs(cfg); err != nil {
+		t.Errorf("HandlePropArgs: error: %s", err)
+	}
+
+	os.Args = append(args[:1:1], "--TestHelp-help")
+	if !uniset.HelpRequested("TestHelp") || uniset.CheckUnknownProps("TestHelp") != nil {
+		t.Error("--TestHelp-help: must be help request")
+	}
+
+	// справка выводится в stdout
+	devnull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer devnull.Close()
+
+	stdout := os.Stdout
+	os.Stdout = devnull
+	defer func() { os.Stdout = stdout }()
+
+	if err := uniset.HandlePropArgs(cfg); err != uniset.ErrHelp {
+		t.Errorf("HandlePropArgs: %v != ErrHelp", err)
+	}
+}
+
+// ----------------------------------------------------------------
+// объект несравнимого типа (со срезом), регистрируемый по значению
+type valueObject struct {
+	id     uniset.ObjectID
+	events chan uniset.UMessage
+	cmds   chan uniset.UMessage
+	tags   []string
+}
+
+func (o valueObject) ID() uniset.ObjectID              { return o.id }
+func (o valueObject) UEvent() chan<- uniset.UMessage   { return o.events }
+func (o valueObject) UCommand() <-chan uniset.UMessage { return o.cmds }
+
+// ----------------------------------------------------------------
+// Команды от объекта несравнимого типа и заказ несуществующего датчика
+// ----------------------------------------------------------------
+func TestCommandsValueObject(t *testing.T) {
+
+	sm := newTestSM(t)
+	uproxy := newTestUProxy(sm, "UProxy1")
+	defer uproxy.Terminate()
+	uproxy.Run()
+
+	obj := valueObject{100, make(chan uniset.UMessage, 10), make(chan uniset.UMessage, 10), []string{"test"}}
+	uproxy.Add(obj)
+
+	uniset.AskSensor(obj.cmds, 12345)
+	uniset.AskSensor(obj.cmds, 20)
+
+	var askResult *uniset.AskCommand
+	var event *uniset.SensorEvent
+
+	timeout := time.After(time.Second)
+	for askResult == nil || event == nil {
+		select {
+		case umsg := <-obj.events:
+			if ask, ok := umsg.PopAsAskCommand(); ok {
+				askResult = ask
+			}
+			if sm, ok := umsg.PopAsSensorEvent(); ok {
+				event = sm
+			}
+		case <-timeout:
+			t.Fatalf("no replies: ask=%v event=%v", askResult, event)
+		}
+	}
+
+	if askResult.Id != 12345 || askResult.Result {
+		t.Errorf("ask unknown sensor: bad reply %+v (must be Result=false)", askResult)
+	}
+
+	if event.Id != 20 || event.Value != 20 {
+		t.Errorf("ask sensor: bad reply %v", event)
+	}
+}
+
+// ----------------------------------------------------------------
+// backend считающий заказы и отказы по датчикам
+type countingBackend struct {
+	uniset.Backend
+	mut   sync.Mutex
+	asks  map[uniset.ObjectID]int
+	unask map[uniset.ObjectID]int
+}
+
+func newCountingBackend(b uniset.Backend) *countingBackend {
+	return &countingBackend{Backend: b, asks: make(map[uniset.ObjectID]int), unask: make(map[uniset.ObjectID]int)}
+}
+
+func (b *countingBackend) AskSensor(sid uniset.ObjectID) error {
+	b.mut.Lock()
+	b.asks[sid]++
+	b.mut.Unlock()
+	return b.Backend.AskSensor(sid)
+}
+
+func (b *countingBackend) UnaskSensor(sid uniset.ObjectID) error {
+	b.mut.Lock()
+	b.unask[sid]++
+	b.mut.Unlock()
+	return b.Backend.UnaskSensor(sid)
+}
+
+func (b *countingBackend) counts(sid uniset.ObjectID) (int, int) {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	return b.asks[sid], b.unask[sid]
+}
+
+// ----------------------------------------------------------------
+// ожидание сообщения удовлетворяющего условию (остальные пропускаются)
+func waitMessage(t *testing.T, c *TestObject, what string, match func(umsg *uniset.UMessage) bool) {
+
+	t.Helper()
+
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case umsg := <-c.rchannel:
+			if match(&umsg) {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("object %d: %s not received", c.ID(), what)
+		}
+	}
+}
+
+// ----------------------------------------------------------------
+// значения датчика sid полученные объектом за время timeout
+func readValues(c *TestObject, sid uniset.ObjectID, timeout time.Duration) []int64 {
+
+	var values []int64
+	for {
+		select {
+		case umsg := <-c.rchannel:
+			if sm, ok := umsg.PopAsSensorEvent(); ok && sm.Id == sid {
+				values = append(values, sm.Value)
+			}
+		case <-time.After(timeout):
+			return values
+		}
+	}
+}
+
+// ----------------------------------------------------------------
+// Список заказчиков: повторный заказ, удаление из середины и с конца,
+// удаление объекта заказавшего несколько датчиков, отказ последнего заказчика
+// ----------------------------------------------------------------
+func TestAskRegistry(t *testing.T) {
+
+	sm := newTestSM(t)
+	backend := newCountingBackend(sm.NewBackend())
+	uproxy := uniset.NewUProxyWithBackend("UProxy1", backend, 2000, 20, 100, 200)
+	defer uproxy.Terminate()
+	uproxy.Run()
+
+	waitAsk := func(c *TestObject, sid uniset.ObjectID) {
+		c.AskSensor(sid)
+		waitMessage(t, c, fmt.Sprintf("ask reply for %d", sid), func(umsg *uniset.UMessage) bool {
+			sm, ok := umsg.PopAsSensorEvent()
+			return ok && sm.Id == sid
+		})
+	}
+
+	waitUnask := func(c *TestObject, sid uniset.ObjectID) {
+		uniset.UnaskSensor(c.wchannel, sid)
+		waitMessage(t, c, fmt.Sprintf("unask reply for %d", sid), func(umsg *uniset.UMessage) bool {
+			cmd, ok := umsg.PopAsUnaskCommand()
+			return ok && cmd.Id == sid && cmd.Result
+		})
+	}
+
+	waitRemove := func(c *TestObject) {
+		uproxy.Remove(c.ID())
+		waitMessage(t, c, "FinishEvent", func(umsg *uniset.UMessage) bool {
+			_, ok := umsg.PopAsFinishEvent()
+			return ok
+		})
+	}
+
+	// проверка кто получает изменения датчика
+	check := func(sid uniset.ObjectID, value int64, receivers []*TestObject, others []*TestObject) {
+		sm.SetValue(sid, value, uniset.DefaultObjectID)
+		for _, c := range receivers {
+			if v := readValues(c, sid, 100*time.Millisecond); len(v) != 1 || v[0] != value {
+				t.Errorf("sensor %d=%d: object %d received %v", sid, value, c.ID(), v)
+			}
+		}
+		for _, c := range others {
+			if v := readValues(c, sid, 10*time.Millisecond); len(v) != 0 {
+				t.Errorf("sensor %d=%d: object %d must not receive, but received %v", sid, value, c.ID(), v)
+			}
+		}
+	}
+
+	clist := makeUObjects(100, 3)
+	a, b, c := clist[0], clist[1], clist[2]
+
+	for _, o := range clist {
+		uproxy.Add(o)
+		waitAsk(o, 20)
+		waitAsk(o, 1)
+	}
+
+	// повторный заказ не добавляет объект в список второй раз
+	waitAsk(a, 20)
+	check(20, 55, clist, nil)
+
+	if asks, _ := backend.counts(20); asks != 1 {
+		t.Errorf("backend AskSensor(20) called %d times (must be 1)", asks)
+	}
+
+	// удаление из середины списка: на место b встаёт c
+	waitRemove(b)
+	check(20, 56, []*TestObject{a, c}, nil)
+	check(1, 0, []*TestObject{a, c}, nil)
+
+	// удаление с конца списка (c после перестановки последний)
+	waitUnask(c, 20)
+	check(20, 57, []*TestObject{a}, []*TestObject{c})
+
+	if _, unask := backend.counts(20); unask != 0 {
+		t.Errorf("backend UnaskSensor(20) called %d times while consumers left", unask)
+	}
+
+	// отказ последнего заказчика - отказ от реального заказа (ровно один раз)
+	waitUnask(a, 20)
+	check(20, 58, nil, []*TestObject{a, c})
+
+	if _, unask := backend.counts(20); unask != 1 {
+		t.Errorf("backend UnaskSensor(20) called %d times (must be 1)", unask)
+	}
+
+	// удаление объекта заказавшего датчик 1 (первый элемент списка)
+	waitRemove(a)
+	check(1, 1, []*TestObject{c}, nil)
+
+	if _, unask := backend.counts(1); unask != 0 {
+		t.Errorf("backend UnaskSensor(1) called %d times while consumers left", unask)
+	}
+
+	waitRemove(c)
+
+	if _, unask := backend.counts(1); unask != 1 {
+		t.Errorf("backend UnaskSensor(1) called %d times (must be 1)", unask)
+	}
+
+	if _, unask := backend.counts(20); unask != 1 {
+		t.Errorf("backend UnaskSensor(20) called %d times after Remove (must be 1)", unask)
+	}
+}
+
+// ----------------------------------------------------------------
+// Политики доставки сообщений "медленным" объектам
+// ----------------------------------------------------------------
+func TestDeliveryPolicy(t *testing.T) {
+
+	sm := newTestSM(t)
+	uproxy := newTestUProxy(sm, "UProxy1")
+
+	policies := []uniset.DeliveryPolicy{
+		{Mode: uniset.DeliverDropNewest},
+		{Mode: uniset.DeliverBlock, Timeout: 10 * time.Millisecond},
+		{Mode: uniset.DeliverDropOldest, QueueSize: 3, Timeout: 50 * time.Millisecond},
+		{Mode: uniset.DeliverLatest, Timeout: 50 * time.Millisecond},
+	}
+
+	var drops sync.Map
+	uproxy.SetDropHandler(func(id uniset.ObjectID, msg *uniset.UMessage) {
+		drops.Store(id, true)
+	})
+
+	var clist []*TestObject
+	for i, p := range policies {
+		c := &TestObject{uniset.ObjectID(100 + i), make(chan uniset.UMessage, 2), make(chan uniset.UMessage, 10), 0, 0, 0}
+		uproxy.SetDeliveryPolicy(c.ID(), p)
+		clist = append(clist, c)
+	}
+
+	defer uproxy.Terminate()
+	uproxy.Run()
+
+	for _, c := range clist {
+		uproxy.Add(c)
+		c.AskSensor(20)
+	}
+
+	// ActivateEvent и ответ на заказ заполняют канал объекта
+	time.Sleep(300 * time.Millisecond)
+
+	for v := int64(1); v <= 10; v++ {
+		sm.SetValue(20, v, uniset.DefaultObjectID)
+	}
+
+	time.Sleep(500 * time.Millisecond)
+
+	stats := make(map[uniset.ObjectID]uniset.DeliveryStat)
+	for _, st := range uproxy.DeliveryStats() {
+		stats[st.ID] = st
+	}
+
+	for _, c := range clist {
+		st := stats[c.ID()]
+		if st.Dropped == 0 {
+			t.Errorf("%s: dropped must be > 0", st.Mode)
+		}
+		if _, ok := drops.Load(c.ID()); !ok {
+			t.Errorf("%s: drop handler is not called", st.Mode)
+		}
+	}
+
+	// в очереди latest не больше одного значения по датчику
+	if stats[clist[3].ID()].Pending > 1 {
+		t.Errorf("latest: pending %d > 1", stats[clist[3].ID()].Pending)
+	}
+
+	// после разгрузки объекта потерянные значения должны быть досланы (Resync)
+	lastEvent := func(c *TestObject) *uniset.SensorEvent {
+		var last *uniset.SensorEvent
+		for {
+			select {
+			case umsg := <-c.rchannel:
+				if sm, ok := umsg.PopAsSensorEvent(); ok {
+					last = sm
+				}
+			case <-time.After(200 * time.Millisecond):
+				return last
+			}
+		}
+	}
+
+	// досылка приходит сама, как только объект разбирает свой канал
+	for i, c := range clist {
+
+		sm := lastEvent(c)
+		if sm == nil || sm.Value != 10 {
+			t.Errorf("%s: last event %v (must be value 10)", policies[i].Mode, sm)
+			continue
+		}
+
+		// в этих режимах последнее значение было отброшено
+		resync := policies[i].Mode == uniset.DeliverDropNewest || policies[i].Mode == uniset.DeliverBlock
+		if sm.Resync != resync {
+			t.Errorf("%s: resync flag %v != %v", policies[i].Mode, sm.Resync, resync)
+		}
+	}
+
+	// повторно не досылается
+	for _, c := range clist {
+		c.SensorEventCounter = 0
+		c.ReadEvent(100)
+		if c.SensorEventCounter != 0 {
+			t.Errorf("resync: unexpected %d events for %d", c.SensorEventCounter, c.ID())
+		}
+	}
+}
+
+// ----------------------------------------------------------------
+// Ожидание в DeliverBlock не должно задерживать доставку другим объектам
+// ----------------------------------------------------------------
+func TestDeliveryBlockIsolation(t *testing.T) {
+
+	sm := newTestSM(t)
+	uproxy := newTestUProxy(sm, "UProxy1")
+	uproxy.SetDefaultDeliveryPolicy(uniset.DeliveryPolicy{Mode: uniset.DeliverBlock, Timeout: time.Second})
+
+	// медленный объект не разбирает свой канал
+	slow := &TestObject{100, make(chan uniset.UMessage, 1), make(chan uniset.UMessage, 10), 0, 0, 0}
+	fast := makeUObjects(101, 1)[0]
+
+	defer uproxy.Terminate()
+	uproxy.Run()
+
+	for _, c := range []*TestObject{slow, fast} {
+		uproxy.Add(c)
+		c.AskSensor(20)
+	}
+
+	waitMessage(t, fast, "ask reply", func(umsg *uniset.UMessage) bool {
+		_, ok := umsg.PopAsSensorEvent()
+		return ok
+	})
+
+	start := time.Now()
+	for v := int64(1); v <= 5; v++ {
+
+		sm.SetValue(20, v, uniset.DefaultObjectID)
+
+		waitMessage(t, fast, fmt.Sprintf("value %d", v), func(umsg *uniset.UMessage) bool {
+			e, ok := umsg.PopAsSensorEvent()
+			return ok && e.Value == v
+		})
+	}
+
+	// при ожидании в общей go-рутине каждое событие задерживалось бы на Timeout
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Errorf("delivery to fast object took %s", d)
+	}
+}
+
+// ----------------------------------------------------------------
+// Объекты не разбирающие свой канал не должны оставлять
+// go-рутины доставки после Remove и Shutdown
+// ----------------------------------------------------------------
+func TestDeliveryClose(t *testing.T) {
+
+	sm := newTestSM(t)
+	uproxy := newTestUProxy(sm, "UProxy1")
+	uproxy.SetDefaultDeliveryPolicy(uniset.DeliveryPolicy{Mode: uniset.DeliverDropOldest, QueueSize: 20, Timeout: 50 * time.Millisecond})
+	uproxy.SetDeliveryPolicy(100, uniset.DeliveryPolicy{Mode: uniset.DeliverLatest, Timeout: 50 * time.Millisecond})
+
+	// статистика удаляется вместе с объектом, поэтому потери считаем через обработчик
+	var dropmut sync.Mutex
+	drops := make(map[uniset.ObjectID]int)
+	uproxy.SetDropHandler(func(id uniset.ObjectID, msg *uniset.UMessage) {
+		dropmut.Lock()
+		drops[id]++
+		dropmut.Unlock()
+	})
+
+	uproxy.Run()
+	base := runtime.NumGoroutine()
+
+	clist := makeUObjects(100, 20)
+	for _, c := range clist {
+		uproxy.Add(c)
+		c.AskSensor(20)
+	}
+
+	// ждём, пока каналы объектов заполнятся до n сообщений
+	waitFill := func(n int) {
+		deadline := time.Now().Add(time.Second)
+		for _, c := range clist {
+			for len(c.rchannel) < n && time.Now().Before(deadline) {
+				time.Sleep(10 * time.Millisecond)
+			}
+			if len(c.rchannel) < n {
+				t.Fatalf("object %d: %d messages in channel (must be %d)", c.ID(), len(c.rchannel), n)
+			}
+		}
+	}
+
+	// ActivateEvent и ответ на заказ
+	waitFill(2)
+
+	for v := int64(1); v <= 15; v++ {
+		sm.SetValue(20, v, uniset.DefaultObjectID)
+	}
+
+	// каналы объектов заполнены, остальное ждёт в очереди UProxy
+	waitFill(cap(clist[0].rchannel))
+
+	for _, c := range clist[:5] {
+		uproxy.Remove(c.ID())
+	}
+
+	// канал закрывается после обработки Remove
+	for _, c := range clist[:5] {
+		for range c.rchannel {
+		}
+	}
+
+	// удалённые объекты не остаются в статистике, а политика объекта сбрасывается
+	readd := makeUObjects(100, 1)[0]
+	uproxy.Add(readd)
+	waitMessage(t, readd, "ActivateEvent", func(umsg *uniset.UMessage) bool {
+		_, ok := umsg.PopAsActivateEvent()
+		return ok
+	})
+
+	stats := uproxy.DeliveryStats()
+	if len(stats) != len(clist)-4 {
+		t.Errorf("DeliveryStats: %d objects after Remove (must be %d)", len(stats), len(clist)-4)
+	}
+
+	for _, st := range stats {
+		if st.ID == readd.ID() && st.Mode != uniset.DeliverDropOldest {
+			t.Errorf("object %d: mode %s after Remove and Add (must be default)", st.ID, st.Mode)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	if err := uproxy.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: error: %s", err)
+	}
+
+	if n := runtime.NumGoroutine(); n > base {
+		t.Errorf("goroutines after Shutdown: %d > %d", n, base)
+	}
+
+	if n := len(uproxy.DeliveryStats()); n != 0 {
+		t.Errorf("DeliveryStats: %d objects after Shutdown", n)
+	}
+
+	// недоставленное (объектам, которые так и не разобрали канал) учтено как отброшенное
+	dropmut.Lock()
+	for _, c := range clist[5:] {
+		if drops[c.ID()] == 0 {
+			t.Errorf("object %d: no drops", c.ID())
+		}
+	}
+	dropmut.Unlock()
+
+	// каналы закрыты
+	for _, c := range clist[5:] {
+		for range c.rchannel {
+		}
+	}
+
+	for range readd.rchannel {
+	}
+}
+
+// ----------------------------------------------------------------
+// Задержка обработки команд (SetValueCommand -> ответ)
+// ----------------------------------------------------------------
+func BenchmarkCommandLatency(b *testing.B) {
+
+	sm := uniset.NewSMemory()
+	sm.AddSensor(1, "Input1_S", 0)
+
+	uproxy := newTestUProxy(sm, "UProxy1")
+	defer uproxy.Terminate()
+	uproxy.Run()
+
+	c := makeUObjects(100, 1)[0]
+	uproxy.Add(c)
+
+	// ждём активации
+	for umsg := range c.rchannel {
+		if _, ok := umsg.PopAsActivateEvent(); ok {
+			break
+		}
+	}
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+
+		// пауза, чтобы UProxy успевал "заскучать" (как в реальной работе)
+		if i%10 == 0 {
+			b.StopTimer()
+			time.Sleep(time.Millisecond)
+			b.StartTimer()
+		}
+
+		uniset.SetValue(c.wchannel, 1, int64(i))
+
+		for umsg := range c.rchannel {
+			if _, ok := umsg.PopAsSetValueCommand(); ok {
+				break
+			}
+		}
+	}
+}
+
+// ----------------------------------------------------------------
+// Задержка при большом количестве объектов (команды идут от одного из них)
+// ----------------------------------------------------------------
+func BenchmarkCommandLatencyManyObjects(b *testing.B) {
+
+	sm := uniset.NewSMemory()
+	sm.AddSensor(1, "Input1_S", 0)
+
+	uproxy := newTestUProxy(sm, "UProxy1")
+	defer uproxy.Terminate()
+	uproxy.Run()
+
+	clist := makeUObjects(100, 500)
+	for _, c := range clist {
+		uproxy.Add(c)
+	}
+
+	c := clist[len(clist)-1]
+	for umsg := range c.rchannel {
+		if _, ok := umsg.PopAsActivateEvent(); ok {
+			break
+		}
+	}
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+
+		uniset.SetValue(c.wchannel, 1, int64(i))
+
+		for umsg := range c.rchannel {
+			if _, ok := umsg.PopAsSetValueCommand(); ok {
+				break
+			}
+		}
+	}
+}
+
+// ----------------------------------------------------------------
+// Рассылка SensorEvent 1000 заказчикам одного датчика
+// ----------------------------------------------------------------
+func BenchmarkFanOut1k(b *testing.B) {
+
+	sm := uniset.NewSMemory()
+	sm.AddSensor(1, "Input1_S", 0)
+
+	uproxy := newTestUProxy(sm, "UProxy1")
+	uproxy.SetDefaultDeliveryPolicy(uniset.DeliveryPolicy{Mode: uniset.DeliverBlock, Timeout: time.Second})
+	defer uproxy.Terminate()
+	uproxy.Run()
+
+	var wg sync.WaitGroup
+	var value atomic.Int64
+
+	clist := makeUObjects(100, 1000)
+	for _, c := range clist {
+		uproxy.Add(c)
+		c.AskSensor(1)
+	}
+
+	// ждём ответов на заказ
+	wg.Add(len(clist))
+	for _, c := range clist {
+		go func(c *TestObject) {
+			asked := false
+			for umsg := range c.rchannel {
+				sm, ok := umsg.PopAsSensorEvent()
+				if !ok {
+					continue
+				}
+				if !asked || sm.Value == value.Load() {
+					asked = true
+					wg.Done()
+				}
+			}
+		}(c)
+	}
+	wg.Wait()
+
+	b.ResetTimer()
+
+	for i := 1; i <= b.N; i++ {
+		wg.Add(len(clist))
+		value.Store(int64(i))
+		sm.SetValue(1, int64(i), uniset.DefaultObjectID)
+		wg.Wait()
+	}
+}
+
+// ----------------------------------------------------------------
+// Заказ/отказ от датчиков при большом количестве заказчиков
+// ----------------------------------------------------------------
+func BenchmarkAskUnask1k(b *testing.B) {
+
+	sm := uniset.NewSMemory()
+	for sid := uniset.ObjectID(1); sid <= 100; sid++ {
+		sm.AddSensor(sid, fmt.Sprintf("Sensor%d_S", sid), 0)
+	}
+
+	uproxy := newTestUProxy(sm, "UProxy1")
+	uproxy.SetDefaultDeliveryPolicy(uniset.DeliveryPolicy{Mode: uniset.DeliverBlock, Timeout: time.Second})
+	defer uproxy.Terminate()
+	uproxy.Run()
+
+	// 1000 объектов заказали по 100 датчиков
+	clist := makeUObjects(100, 1000)
+	for _, c := range clist {
+		uproxy.Add(c)
+		go func(c *TestObject) {
+			for range c.rchannel {
+			}
+		}(c)
+		for sid := uniset.ObjectID(1); sid <= 100; sid++ {
+			c.AskSensor(sid)
+		}
+	}
+
+	c := makeUObjects(5000, 1)[0]
+	uproxy.Add(c)
+
+	waitReply := func() {
+		for umsg := range c.rchannel {
+			if _, ok := umsg.PopAsSensorEvent(); ok {
+				return
+			}
+			if _, ok := umsg.PopAsUnaskCommand(); ok {
+				return
+			}
+		}
+	}
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		sid := uniset.ObjectID(i%100 + 1)
+		c.AskSensor(sid)
+		waitReply()
+		uniset.UnaskSensor(c.wchannel, sid)
+		waitReply()
+	}
+}
+
+// ----------------------------------------------------------------
+// Рассылка с разделением по датчикам (SetDispatchShards)
+// ----------------------------------------------------------------
+func TestShardedDispatch(t *testing.T) {
+
+	const sensors = 16
+	const values = 50
+
+	sm := uniset.NewSMemory()
+	for sid := uniset.ObjectID(1); sid <= sensors; sid++ {
+		sm.AddSensor(sid, fmt.Sprintf("Sensor%d_S", sid), 0)
+	}
+
+	backend := &serialCheckBackend{Backend: sm.NewBackend()}
+	uproxy := uniset.NewUProxyWithBackend("UProxy1", backend, 2000, 20, 100, 200)
+	uproxy.SetDefaultDeliveryPolicy(uniset.DeliveryPolicy{Mode: uniset.DeliverBlock, Timeout: time.Second})
+
+	if err := uproxy.SetDispatchShards(4); err != nil {
+		t.Fatalf("SetDispatchShards: %s", err)
+	}
+
+	defer uproxy.Terminate()
+	uproxy.Run()
+
+	if err := uproxy.SetDispatchShards(2); err == nil {
+		t.Error("SetDispatchShards: must be error while running")
+	}
+
+	clist := makeUObjects(100, 3)
+
+	// состояние объекта (меняется только в его go-рутине чтения,
+	// о достижении нужных состояний сообщается закрытием каналов)
+	type result struct {
+		last     map[uniset.ObjectID]int64
+		disorder int
+		finished bool
+		asked    chan struct{} // получены ответы на заказ всех датчиков
+		filled   chan struct{} // по всем датчикам получено значение values
+		unasked  chan struct{} // получен ответ на UnaskCommand
+		removed  chan struct{} // получен FinishEvent
+		updated1 chan struct{} // по датчику 1 получено значение values+1
+		updated2 chan struct{} // по датчику 2 получено значение values+1
+	}
+
+	results := make([]*result, len(clist))
+	var wg sync.WaitGroup
+
+	for i, c := range clist {
+
+		r := &result{last: make(map[uniset.ObjectID]int64)}
+		for _, ch := range []*chan struct{}{&r.asked, &r.filled, &r.unasked, &r.removed, &r.updated1, &r.updated2} {
+			*ch = make(chan struct{})
+		}
+		results[i] = r
+
+		uproxy.Add(c)
+		for sid := uniset.ObjectID(1); sid <= sensors; sid++ {
+			c.AskSensor(sid)
+		}
+
+		wg.Add(1)
+		go func(c *TestObject) {
+			defer wg.Done()
+
+			done := make(map[chan struct{}]bool)
+			notify := func(ch chan struct{}, cond bool) {
+				if cond && !done[ch] {
+					done[ch] = true
+					close(ch)
+				}
+			}
+
+			for umsg := range c.rchannel {
+				if sm, ok := umsg.PopAsSensorEvent(); ok {
+					if sm.Value < r.last[sm.Id] {
+						r.disorder++
+					}
+					r.last[sm.Id] = sm.Value
+
+					filled := true
+					for sid := uniset.ObjectID(1); sid <= sensors; sid++ {
+						filled = filled && r.last[sid] == values
+					}
+
+					notify(r.asked, len(r.last) == sensors)
+					notify(r.filled, filled)
+					notify(r.updated1, r.last[1] == values+1)
+					notify(r.updated2, r.last[2] == values+1)
+				}
+				if u, ok := umsg.PopAsUnaskCommand(); ok && u.Result {
+					notify(r.unasked, true)
+				}
+				if _, ok := umsg.PopAsFinishEvent(); ok {
+					r.finished = true
+					notify(r.removed, true)
+				}
+			}
+		}(c)
+	}
+
+	wait := func(ch chan struct{}, what string) {
+		select {
+		case <-ch:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("%s: timeout", what)
+		}
+	}
+
+	for i, r := range results {
+		wait(r.asked, fmt.Sprintf("object %d: ask replies", clist[i].ID()))
+	}
+
+	for v := int64(1); v <= values; v++ {
+		for sid := uniset.ObjectID(1); sid <= sensors; sid++ {
+			sm.SetValue(sid, v, uniset.DefaultObjectID)
+		}
+	}
+
+	for i, r := range results {
+		wait(r.filled, fmt.Sprintf("object %d: values", clist[i].ID()))
+	}
+
+	// отказ от датчика и удаление объекта
+	uniset.UnaskSensor(clist[1].wchannel, 1)
+	wait(results[1].unasked, "unask reply")
+
+	uproxy.Remove(clist[2].ID())
+	wait(results[2].removed, "remove")
+
+	sm.SetValue(1, values+1, uniset.DefaultObjectID)
+	sm.SetValue(2, values+1, uniset.DefaultObjectID)
+
+	// после того как новые значения дошли до объекта 0 и (по датчику 2) до объекта 1,
+	// рассылка по этим датчикам закончена: Terminate дожидается go-рутин рассылки
+	wait(results[0].updated1, "object 100: new value of sensor 1")
+	wait(results[0].updated2, "object 100: new value of sensor 2")
+	wait(results[1].updated2, "object 101: new value of sensor 2")
+
+	uproxy.Terminate()
+	wg.Wait()
+
+	if n := backend.overlap.Load(); n > 0 {
+		t.Errorf("backend: %d concurrent calls", n)
+	}
+
+	for i, r := range results {
+
+		if r.disorder > 0 {
+			t.Errorf("object %d: %d events out of order", clist[i].ID(), r.disorder)
+		}
+
+		if !r.finished {
+			t.Errorf("object %d: FinishEvent not received", clist[i].ID())
+		}
+
+		for sid := uniset.ObjectID(3); sid <= sensors; sid++ {
+			if r.last[sid] != values {
+				t.Errorf("object %d: sensor %d last value %d != %d", clist[i].ID(), sid, r.last[sid], values)
+			}
+		}
+	}
+
+	if results[1].last[1] != values {
+		t.Errorf("unask: object %d received sensor 1 value %d after unask", clist[1].ID(), results[1].last[1])
+	}
+
+	if results[2].last[1] != values || results[2].last[2] != values {
+		t.Errorf("remove: removed object received new values")
+	}
+}
+
+// ----------------------------------------------------------------
+// backend проверяющий, что UProxy не вызывает его методы одновременно
+type serialCheckBackend struct {
+	uniset.Backend
+	calls   atomic.Int32
+	overlap atomic.Int32
+}
+
+func (b *serialCheckBackend) enter() {
+	if b.calls.Add(1) > 1 {
+		b.overlap.Add(1)
+	}
+	time.Sleep(time.Millisecond) // чтобы одновременные вызовы успели пересечься
+}
+
+func (b *serialCheckBackend) leave() {
+	b.calls.Add(-1)
+}
+
+func (b *serialCheckBackend) AskSensor(sid uniset.ObjectID) error {
+	b.enter()
+	defer b.leave()
+	return b.Backend.AskSensor(sid)
+}
+
+func (b *serialCheckBackend) UnaskSensor(sid uniset.ObjectID) error {
+	b.enter()
+	defer b.leave()
+	return b.Backend.UnaskSensor(sid)
+}
+
+func (b *serialCheckBackend) GetValue(sid uniset.ObjectID) (int64, error) {
+	b.enter()
+	defer b.leave()
+	return b.Backend.GetValue(sid)
+}
+
+// ----------------------------------------------------------------
+// Поток событий по многим датчикам: одна go-рутина рассылки и разделение на несколько
+// ----------------------------------------------------------------
+func BenchmarkSensorEvents(b *testing.B) {
+
+	for _, shards := range []int{1, 4} {
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			benchmarkSensorEvents(b, shards)
+		})
+	}
+}
+
+func benchmarkSensorEvents(b *testing.B, shards int) {
+
+	const sensors = 64
+	const consumers = 100
+
+	sm := uniset.NewSMemory()
+	sm.SetQueueSize(100000)
+	for sid := uniset.ObjectID(1); sid <= sensors; sid++ {
+		sm.AddSensor(sid, fmt.Sprintf("Sensor%d_S", sid), 0)
+	}
+
+	uproxy := uniset.NewUProxyWithBackend("UProxy1", sm.NewBackend(), 100000, 20, 100, 200)
+	uproxy.SetDefaultDeliveryPolicy(uniset.DeliveryPolicy{Mode: uniset.DeliverBlock, Timeout: time.Second})
+	uproxy.SetDispatchShards(shards)
+	defer uproxy.Terminate()
+	uproxy.Run()
+
+	var received atomic.Int64
+
+	// каждый объект заказывает свою четверть датчиков
+	clist := makeUObjects(100, consumers)
+	for i, c := range clist {
+		uproxy.Add(c)
+		for sid := uniset.ObjectID(1); sid <= sensors; sid++ {
+			if int(sid)%4 == i%4 {
+				c.AskSensor(sid)
+			}
+		}
+		go func(c *TestObject) {
+			for umsg := range c.rchannel {
+				if _, ok := umsg.PopAsSensorEvent(); ok {
+					received.Add(1)
+				}
+			}
+		}(c)
+	}
+
+	// ответы на заказ
+	initial := int64(consumers * sensors / 4)
+	for received.Load() < initial {
+		time.Sleep(time.Millisecond)
+	}
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		sm.SetValue(uniset.ObjectID(i%sensors+1), int64(i+1), uniset.DefaultObjectID)
+	}
+
+	expected := initial + int64(b.N)*consumers/4
+	deadline := time.Now().Add(30 * time.Second)
+	for received.Load() < expected && time.Now().Before(deadline) {
+		time.Sleep(100 * time.Microsecond)
+	}
+}
@@ -10,39 +10,66 @@
 // при помощи функции Add(). А дальше уже при помощи канала команд можно заказывать датчики,
 // а при помоищи канала "событий" получать уведомления об их изменении
 // ---------
+// Команды от объектов собираются в общий канал (см. forwardCommands), отдельной go-рутиной
+// на каждый объект. Поэтому mainLoop обрабатывает команды сразу по поступлении,
+// а когда ничего не происходит - просто спит в select.
+// ---------
+// Для больших потоков событий можно включить режим с разделением (SetDispatchShards),
+// тогда рассылка SensorEvent (и работа со списками заказчиков) ведётся N go-рутинами,
+// каждая для своей части датчиков (см. dispatch.go).
+// ---------
 package uniset
 
 import (
-	"container/list"
+	"context"
 	"errors"
 	"fmt"
 	"os"
-	"os/signal"
 	"sync"
-	"syscall"
 	"time"
-	"uniset_internal_api"
 )
 
 // ----------------------------------------------------------------------------------
 // Объект через который идёт всё взаимодействие с uniset-системой
-// При своём запуске Run() создаётся c++-ный объект который реально работает
+// При своём запуске Run() запускается backend (по умолчанию c++-ный объект) который реально работает
 // с uniset-системой, а UProxy проксирует запросы к нему и обработку сообщений
 // преобразуя их в события в go-каналах.
 // Следует иметь ввиду, что c++-ый Proxy ещё сам создаёт потоки в системе необходимые ему для работы
 type UProxy struct {
-	askmap       map[ObjectID]*consumersList
+	askmap       *askRegistry // заказчики датчиков (см. consumers.go)
+	nshards      int
+	shards       []*dispatchShard // (только в режиме с разделением, см. SetDispatchShards)
+	shardwg      sync.WaitGroup
+	bmutex       sync.Mutex // сериализация вызовов backend в режиме с разделением
+	mqSize       uint
 	active       bool
 	actmutex     sync.RWMutex
-	term         sync.WaitGroup
+	runmutex     sync.Mutex
+	cancel       context.CancelFunc
+	finished     chan struct{} // закрывается по завершении работы (mainLoop и doReadMessages)
 	name         string
 	id           ObjectID
 	confile      string
 	uniset_port  int
-	uproxy       uniset_internal_api.UProxy
+	backend      Backend
 	initOK       bool
 	omap         map[ObjectID]UObject // список зарегистрированных объектов
+	deliv        map[ObjectID]*delivery
+	delivmut     sync.RWMutex
+	delivwg      sync.WaitGroup // go-рутины доставки (см. outbox)
+	policies     map[ObjectID]DeliveryPolicy
+	defpolicy    DeliveryPolicy
+	onDrop       func(id ObjectID, msg *UMessage)
+	polmutex     sync.Mutex
+	resync       chan ObjectID // запросы на досылку потерянных значений (см. delivery)
+	cmd          chan objCommand
+	cmdstop      map[ObjectID]chan struct{} // остановка пересылки команд объекта
+	cmdwg        sync.WaitGroup
 	add          chan UObject
+	del          chan ObjectID
+	reload       chan struct{}
+	sigpolicy    SignalPolicy
+	sigfunc      func(sig os.Signal)
 	msg          chan *SensorEvent
 	eventTimeout uint
 	pollTimeout  uint
@@ -60,19 +87,36 @@ func NewDefaultUProxy(name string) *UProxy {
 // в качестве аргумента передаётся идентификатор
 // используемый для создания c++-объекта
 // mqSize - размер очереди для сообщений об изменении датчиков (приходящих от uniset)
-// oqSize - размер очереди для активации объектов
+// oqSize - размер очереди для активации (и удаления) объектов
 // eventTimeout - timeout (msec) на получение сообщений от uniset-системы
 // pollSensorsTime - период обновления информации о состоянии датчиков (используемой в c++-объекте)
 func NewUProxy(name string, mqSize uint, oqSize uint, eventTimeout uint, pollSensorsTimeout uint) *UProxy {
+	return NewUProxyWithBackend(name, newBackend(name), mqSize, oqSize, eventTimeout, pollSensorsTimeout)
+}
+
+// ----------------------------------------------------------------------------------
+// Создание UProxy работающего через указанный backend
+// (остальные параметры см. NewUProxy(..))
+func NewUProxyWithBackend(name string, b Backend, mqSize uint, oqSize uint, eventTimeout uint, pollSensorsTimeout uint) *UProxy {
 	ui := UProxy{}
-	ui.askmap = make(map[ObjectID]*consumersList)
+	ui.backend = b
+	ui.askmap = newAskRegistry()
 	ui.active = false
 	ui.name = name
 	ui.id = ObjectID(DefaultObjectID)
 	ui.initOK = false
 	ui.omap = make(map[ObjectID]UObject)
+	ui.deliv = make(map[ObjectID]*delivery)
+	ui.policies = make(map[ObjectID]DeliveryPolicy)
+	ui.resync = make(chan ObjectID, oqSize)
+	ui.cmd = make(chan objCommand, oqSize)
+	ui.cmdstop = make(map[ObjectID]chan struct{})
 	ui.add = make(chan UObject, oqSize)
+	ui.del = make(chan ObjectID, oqSize)
+	ui.reload = make(chan struct{}, 1)
+	ui.sigpolicy = SignalTerminate
 	ui.msg = make(chan *SensorEvent, mqSize)
+	ui.mqSize = mqSize
 	ui.eventTimeout = eventTimeout
 	ui.pollTimeout = pollSensorsTimeout
 
@@ -93,81 +137,200 @@ func (ui *UProxy) Add(obj UObject) {
 	ui.add <- obj
 }
 
+// ----------------------------------------------------------------------------------
+// Задать политику доставки сообщений для объекта (см. DeliveryPolicy)
+// Политика применяется при регистрации объекта (Add), поэтому задавать её надо до Add.
+// После Remove (и завершения работы) политика сбрасывается, при повторном Add её надо задать заново
+func (ui *UProxy) SetDeliveryPolicy(id ObjectID, p DeliveryPolicy) {
+
+	ui.polmutex.Lock()
+	defer ui.polmutex.Unlock()
+	ui.policies[id] = p
+}
+
+// ----------------------------------------------------------------------------------
+// Задать политику доставки для объектов, у которых она не задана явно
+// (по умолчанию DeliverDropNewest)
+func (ui *UProxy) SetDefaultDeliveryPolicy(p DeliveryPolicy) {
+
+	ui.polmutex.Lock()
+	defer ui.polmutex.Unlock()
+	ui.defpolicy = p
+}
+
+// ----------------------------------------------------------------------------------
+// Обработчик вызываемый при каждом отброшенном (недоставленном) сообщении
+// Вызывается из внутренних go-рутин UProxy, поэтому должен быть быстрым
+func (ui *UProxy) SetDropHandler(fn func(id ObjectID, msg *UMessage)) {
+
+	ui.polmutex.Lock()
+	defer ui.polmutex.Unlock()
+	ui.onDrop = fn
+}
+
+// ----------------------------------------------------------------------------------
+// Статистика доставки сообщений по зарегистрированным объектам
+// (после Remove статистика объекта удаляется, для учёта потерь см. SetDropHandler)
+func (ui *UProxy) DeliveryStats() []DeliveryStat {
+
+	ui.delivmut.RLock()
+	defer ui.delivmut.RUnlock()
+
+	list := make([]DeliveryStat, 0, len(ui.deliv))
+	for _, d := range ui.deliv {
+		list = append(list, d.stat())
+	}
+
+	return list
+}
+
+// ----------------------------------------------------------------------------------
+// Удалить (отключить) UObject
+// Объект отписывается от всех датчиков, получает FinishEvent
+// после чего его канал событий закрывается
+// (после досылки очереди объекта, но не дольше DeliveryPolicy.Timeout)
+func (ui *UProxy) Remove(id ObjectID) {
+	ui.del <- id
+}
+
 // ----------------------------------------------------------------------------------
 // Завершить работу
+// (ожидание завершения без ограничения по времени, см. Shutdown)
 func (ui *UProxy) Terminate() error {
 
-	if ui.IsActive() {
-		ui.setActive(false)
-		ui.term.Wait()
+	return ui.Shutdown(context.Background())
+}
+
+// ----------------------------------------------------------------------------------
+// Завершить работу, ожидая завершения не дольше чем позволяет ctx
+// Если за отведённое время работа не завершилась, возвращается ctx.Err()
+func (ui *UProxy) Shutdown(ctx context.Context) error {
+
+	ui.runmutex.Lock()
+	cancel := ui.cancel
+	finished := ui.finished
+	ui.runmutex.Unlock()
+
+	if cancel == nil {
+		return nil
 	}
 
-	return nil
+	cancel()
+
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 // ----------------------------------------------------------------------------------
 // Ожидание завершения работы
 func (ui *UProxy) WaitFinish() {
 
-	if !ui.IsActive() {
+	ui.runmutex.Lock()
+	finished := ui.finished
+	ui.runmutex.Unlock()
+
+	if finished == nil {
 		return
 	}
 
-	ui.term.Wait()
+	<-finished
 }
 
 // ----------------------------------------------------------------------------------
 // Начать работу
+// Функция не блокирующая. Для завершения см. Terminate(), Shutdown()
+// После завершения можно запустить снова, но объекты при завершении удаляются
+// (их каналы закрываются), поэтому их нужно добавить заново (см. Add)
 func (ui *UProxy) Run() error {
 
+	return ui.start(context.Background())
+}
+
+// ----------------------------------------------------------------------------------
+// Начать работу и ждать её завершения.
+// Работа завершается при отмене ctx (или вызове Terminate/Shutdown)
+// Если работа завершена из-за отмены ctx, возвращается ctx.Err(),
+// при завершении через Terminate/Shutdown - nil.
+// Удобно для запуска под управлением errgroup и т.п.:
+//
+//	g.Go(func() error { return uproxy.RunContext(ctx) })
+func (ui *UProxy) RunContext(ctx context.Context) error {
+
+	if err := ui.start(ctx); err != nil {
+		return err
+	}
+
+	ui.WaitFinish()
+	return ctx.Err()
+}
+
+// ----------------------------------------------------------------------------------
+func (ui *UProxy) start(ctx context.Context) error {
+
+	ui.runmutex.Lock()
+	defer ui.runmutex.Unlock()
+
 	if ui.IsActive() {
 		return nil
 	}
 
-	if !uniset_internal_api.IsUniSetInitOK() {
-		panic("Not uniset init...")
+	// при завершении предыдущего запуска backend останавливается (см. mainLoop),
+	// поэтому дожидаемся окончания завершения и запускаем его заново
+	if ui.finished != nil {
+		<-ui.finished
+		ui.initOK = false
 	}
 
 	if !ui.initOK {
-		ui.uproxy = uniset_internal_api.NewUProxy(ui.name)
-		ui.uproxy.Run(int(ui.pollTimeout))
+		if err := ui.backend.Run(ui.pollTimeout); err != nil {
+			return err
+		}
 		ui.initOK = true
-
-		signalChannel := make(chan os.Signal, 2)
-		signal.Notify(signalChannel, os.Interrupt, syscall.SIGTERM)
-		go func() {
-			sig := <-signalChannel
-			switch sig {
-			case os.Interrupt:
-				ui.Terminate()
-			case syscall.SIGTERM:
-				ui.Terminate()
-			}
-		}()
-		// ...
 	}
 
+	ctx, ui.cancel = context.WithCancel(ctx)
+	ui.startSignals(ctx, ui.cancel)
+	ui.startShards(ctx)
+	finished := make(chan struct{})
+	ui.finished = finished
+
 	ui.setActive(true)
 
-	ui.term.Add(2)
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+		ui.mainLoop(ctx)
+	}()
 
-	go ui.mainLoop()
-	go ui.doReadMessages()
+	go func() {
+		defer wg.Done()
+		ui.doReadMessages(ctx)
+	}()
+
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
 
 	return nil
 }
 
 // ----------------------------------------------------------------------------------
 // получить значение (напрямую из proxy)
+// Можно вызывать из go-рутин объектов (например через DoReadInputsFrom)
 func (ui *UProxy) GetValue(sid ObjectID) (int64, error) {
 
-	ret := ui.uproxy.SafeGetValue(int64(sid))
+	// в режиме с разделением вызовы backend сериализуются (см. lockBackend)
+	ui.bmutex.Lock()
+	defer ui.bmutex.Unlock()
 
-	if !ret.GetOk() {
-		return 0, errors.New(ret.GetErr())
-	}
-
-	return ret.GetValue(), nil
+	return ui.backend.GetValue(sid)
 }
 
 // ----------------------------------------------------------------------------------
@@ -179,74 +342,78 @@ func (ui *UProxy) setActive(set bool) {
 
 // ----------------------------------------------------------------------------------
 // Главная go-рутина исполняющая команды поступающие от объектов
-func (ui *UProxy) mainLoop() {
-
-	defer ui.term.Done()
+// Работает до отмены ctx
+func (ui *UProxy) mainLoop(ctx context.Context) {
 
+loop:
 	for {
-
-		if !ui.IsActive() {
-			break
-		}
-
 		select {
+		case <-ctx.Done():
+			break loop
+
 		case obj, ok := <-ui.add:
 
 			if ok {
 				ui.doAdd(obj)
 			}
 
-			if !ui.IsActive() {
-				break
+		case id, ok := <-ui.del:
+
+			if ok {
+				ui.doRemove(id)
 			}
 
-		case msg, ok := <-ui.msg:
+		case <-ui.reload:
+			ui.doReload()
 
-			if !ui.IsActive() {
-				break
-			}
+		case id := <-ui.resync:
+			ui.doResync(id)
 
-			if ok {
-				ui.doSensorEvent(msg)
-			}
+		case c := <-ui.cmd:
+			ui.doCommand(c)
 
-		default:
-			if !ui.IsActive() {
-				break
-			}
-			if !ui.doCommands() {
-				time.Sleep(100 * time.Millisecond)
+		case msg, ok := <-ui.msg:
+
+			if ok {
+				ui.doSensorEvent(ui.askmap, msg)
 			}
 		}
 	}
 
+	ui.setActive(false)
+	ui.shardwg.Wait()
 	ui.doFinish()
-	ui.uproxy.Terminate()
+	ui.cmdwg.Wait()
+	ui.delivwg.Wait()
+
+	// в том числе прерывает ожидание в WaitMessage (см. doReadMessages)
+	ui.backend.Terminate()
 }
 
 // ----------------------------------------------------------------------------------
 // Главная go-рутина читающая сообщения от c++ объекта
-func (ui *UProxy) doReadMessages() {
-
-	defer ui.term.Done()
+// Работает до отмены ctx
+func (ui *UProxy) doReadMessages(ctx context.Context) {
 
 	for {
 
-		m := ui.uproxy.SafeWaitMessage(5000)
+		msg, ok := ui.backend.WaitMessage(ui.eventTimeout)
 
-		if !ui.IsActive() {
-			break
+		if ctx.Err() != nil {
+			return
 		}
 
-		if !m.GetOk() {
+		if !ok {
 			continue
 		}
 
-		msg := makeSensorEvent(m.GetSinfo())
-
 		// чтобы вся обработка проходила через одну го-рутину
-		// пересылаем сообщение в mainLoop
-		ui.msg <- msg
+		// пересылаем сообщение в mainLoop (или go-рутину обслуживающую этот датчик)
+		select {
+		case ui.eventQueue(msg.Id) <- msg:
+		case <-ctx.Done():
+			return
+		}
 	}
 }
 
@@ -261,16 +428,57 @@ func (ui *UProxy) doAdd(obj UObject) {
 }
 
 // ----------------------------------------------------------------------------------
-// Рассылка всем уведомления о завершении работы и закрытие канала
-func (ui *UProxy) doFinish() {
+// Удаление объекта
+func (ui *UProxy) doRemove(id ObjectID) {
+
+	obj, found := ui.omap[id]
+	if !found {
+		return
+	}
+
+	// после этого объект не остаётся ни в одном списке заказчиков
+	// и рассылка ему прекращается
+	ui.forEachRegistry(func(reg *askRegistry) {
+		for _, sid := range reg.sensors(id) {
+			ui.doUnaskSensor(reg, sid, id)
+		}
+	})
+
+	delete(ui.omap, id)
+	ui.stopCommands(id)
+
+	ui.send(obj, UMessage{&FinishEvent{}})
+	ui.closeEvents(obj)
+
+	// go-рутина доставки досылает очередь сама (см. delivery.close),
+	// а состояние и политика объекта больше не нужны
+	ui.delivmut.Lock()
+	delete(ui.deliv, id)
+	ui.delivmut.Unlock()
 
-	msg := UMessage{&FinishEvent{}}
+	ui.polmutex.Lock()
+	delete(ui.policies, id)
+	ui.polmutex.Unlock()
+}
+
+// ----------------------------------------------------------------------------------
+// Рассылка всем уведомления ReloadEvent
+func (ui *UProxy) doReload() {
+
+	msg := UMessage{&ReloadEvent{}}
 	for _, obj := range ui.omap {
 		ui.send(obj, msg)
 	}
+}
 
-	for _, obj := range ui.omap {
-		close(obj.UEvent())
+// ----------------------------------------------------------------------------------
+// Рассылка всем уведомления о завершении работы и закрытие канала
+func (ui *UProxy) doFinish() {
+
+	// объекты удаляются полностью (в том числе из списков заказчиков),
+	// чтобы при повторном запуске их можно было добавить заново (см. Add)
+	for id := range ui.omap {
+		ui.doRemove(id)
 	}
 }
 
@@ -278,20 +486,18 @@ func (ui *UProxy) doFinish() {
 // обработка команды "установить значение"
 func (ui *UProxy) doSetValue(sid ObjectID, value int64, supplier ObjectID) error {
 
-	ret := ui.uproxy.SafeSetValue(int64(sid), value)
-	if !ret.GetOk() {
-		return errors.New(ret.GetErr())
-	}
-	return nil
+	ui.lockBackend()
+	defer ui.unlockBackend()
+
+	return ui.backend.SetValue(sid, value, supplier)
 }
 
 // ----------------------------------------------------------------------------------
 // Рассылка SensorEvent
-func (ui *UProxy) doSensorEvent(m *SensorEvent) {
+func (ui *UProxy) doSensorEvent(reg *askRegistry, m *SensorEvent) {
 
-	lst, found := ui.askmap[m.Id]
-	if !found {
-		//fmt.Printf("sensor %d not found in askmap\n", m.Id)
+	lst := reg.consumers(m.Id)
+	if lst == nil {
 		return
 	}
 
@@ -300,55 +506,152 @@ func (ui *UProxy) doSensorEvent(m *SensorEvent) {
 }
 
 // ----------------------------------------------------------------------------------
-func (ui *UProxy) doCommands() bool {
+// Досылка объекту текущих значений датчиков, события по которым были потеряны
+func (ui *UProxy) doResync(id ObjectID) {
 
-	ret := false
-	for _, v := range ui.omap {
-		if ui.doCommandFromObject(v) {
-			ret = true
-		}
+	if _, found := ui.omap[id]; !found {
+		return
+	}
+
+	d, found := ui.deliv[id]
+	if !found {
+		return
+	}
+
+	for _, sid := range d.takeLost() {
+
+		sid := sid
+		ui.withRegistry(sid, func(reg *askRegistry) {
+
+			// объект мог уже отказаться от датчика
+			if !reg.contains(sid, id) {
+				return
+			}
+
+			ui.lockBackend()
+			val, err := ui.backend.GetValue(sid)
+			ui.unlockBackend()
+
+			if err != nil {
+				return
+			}
+
+			d.send(UMessage{&SensorEvent{Id: sid, Value: val, Timestamp: time.Now(), Resync: true}})
+		})
 	}
+}
 
-	return ret
+// ----------------------------------------------------------------------------------
+// команда от объекта (пересылаемая в mainLoop)
+type objCommand struct {
+	obj UObject
+	msg UMessage
+	reg chan struct{} // регистрация объекта, от которой пришла команда (см. cmdstop)
 }
 
 // ----------------------------------------------------------------------------------
-func (ui *UProxy) doCommandFromObject(obj UObject) bool {
+// запуск пересылки команд объекта в mainLoop
+func (ui *UProxy) startCommands(obj UObject) {
 
-	select {
-	case umsg, ok := <-obj.UCommand():
+	if _, found := ui.cmdstop[obj.ID()]; found {
+		return
+	}
 
-		if !ok {
-			return false
+	stop := make(chan struct{})
+	ui.cmdstop[obj.ID()] = stop
+
+	ui.cmdwg.Add(1)
+	go func() {
+		defer ui.cmdwg.Done()
+		ui.forwardCommands(obj, stop)
+	}()
+}
+
+// ----------------------------------------------------------------------------------
+func (ui *UProxy) stopCommands(id ObjectID) {
+
+	if stop, found := ui.cmdstop[id]; found {
+		close(stop)
+		delete(ui.cmdstop, id)
+	}
+}
+
+// ----------------------------------------------------------------------------------
+// go-рутина пересылающая команды объекта в общий канал
+// (порядок команд одного объекта сохраняется)
+func (ui *UProxy) forwardCommands(obj UObject, stop chan struct{}) {
+
+	for {
+		select {
+		case <-stop:
+			return
+
+		case umsg, ok := <-obj.UCommand():
+
+			if !ok {
+				return
+			}
+
+			select {
+			case ui.cmd <- objCommand{obj, umsg, stop}:
+			case <-stop:
+				return
+			}
 		}
+	}
+}
+
+// ----------------------------------------------------------------------------------
+// обработка команды от объекта
+func (ui *UProxy) doCommand(c objCommand) {
+
+	obj, umsg := c.obj, c.msg
+
+	// объект мог быть удалён (или удалён и снова добавлен), пока команда была в пути.
+	// Сравниваем регистрацию, а не сами объекты: UObject может быть несравнимого типа
+	if stop, found := ui.cmdstop[obj.ID()]; !found || stop != c.reg {
+		return
+	}
+
+	d, found := ui.deliv[obj.ID()]
+	if !found {
+		return
+	}
 
-		msg, ok := umsg.PopAsAskCommand()
-		if ok {
-			ret, err := ui.doAskSensor(msg.Id, obj)
+	// заказ и отказ выполняются там, где обслуживается датчик (см. withRegistry),
+	// поэтому ответ посылается напрямую через d
+	msg, ok := umsg.PopAsAskCommand()
+	if ok {
+		ui.withRegistry(msg.Id, func(reg *askRegistry) {
+			ret, err := ui.doAskSensor(reg, msg.Id, d)
 			if err != nil {
 				msg.Result = false
-				ui.send(obj, UMessage{&msg})
+				d.send(UMessage{msg})
 			} else {
-				ui.send(obj, *ret)
+				d.send(*ret)
 			}
+		})
 
-			return true
-		}
-
-		ask, ok := umsg.PopAsSetValueCommand()
-		if ok {
-			err := ui.doSetValue(ask.Id, int64(ask.Value), obj.ID())
-			ask.Result = (err == nil)
-			ui.send(obj, UMessage{&ask})
-			return true
-		}
-
-		return true
+		return
+	}
 
-	default:
+	unask, ok := umsg.PopAsUnaskCommand()
+	if ok {
+		ui.withRegistry(unask.Id, func(reg *askRegistry) {
+			err := ui.doUnaskSensor(reg, unask.Id, obj.ID())
+			unask.Result = (err == nil)
+			d.send(UMessage{unask})
+		})
+		return
 	}
 
-	return false
+	ask, ok := umsg.PopAsSetValueCommand()
+	if ok {
+		err := ui.doSetValue(ask.Id, int64(ask.Value), obj.ID())
+		ask.Result = (err == nil)
+		ui.send(obj, UMessage{ask})
+		return
+	}
 }
 
 // ----------------------------------------------------------------------------------
@@ -361,110 +664,108 @@ func (ui *UProxy) doAddObject(obj UObject) bool {
 	}
 
 	ui.omap[obj.ID()] = obj
+	ui.startCommands(obj)
+
+	ui.polmutex.Lock()
+	policy, found := ui.policies[obj.ID()]
+	if !found {
+		policy = ui.defpolicy
+	}
+	onDrop := ui.onDrop
+	ui.polmutex.Unlock()
+
+	ui.delivmut.Lock()
+	ui.deliv[obj.ID()] = newDelivery(obj, policy, onDrop, ui.resync, &ui.delivwg)
+	ui.delivmut.Unlock()
+
 	return true
 }
 
 // ----------------------------------------------------------------------------------
 // обработка команды "заказ датчика"
-func (ui *UProxy) doAskSensor(sid ObjectID, cons UObject) (msg *UMessage, err error) {
+func (ui *UProxy) doAskSensor(reg *askRegistry, sid ObjectID, cons *delivery) (msg *UMessage, err error) {
 
 	//fmt.Printf("ASK SENSOR: %d for uobjecter %d\n",Sid,cons.ID())
 
-	// На текущий момент uniset_internal_api.UProxy
-	// не поддерживает заказ датчиков..
-	// сперва делаем реальный заказ
-	//ret := ui.uproxy.SafeAskSensor(int64(Sid))
-	//
-	//if !ret.GetOk() {
-	//	return errors.New(fmt.Sprintf("%s (doAskSensor): Sid=%d error: %s", ui.name, Sid, ret.GetErr()))
-	//}
-
-	// Поэтому сперва получаем текущее значение
-	val, err := ui.GetValue(sid)
+	ui.lockBackend()
+	defer ui.unlockBackend()
+
+	// сперва делаем реальный заказ (только если датчик ещё никем не заказан)
+	if reg.consumers(sid) == nil {
+		if err := ui.backend.AskSensor(sid); err != nil {
+			return nil, errors.New(fmt.Sprintf("%s (doAskSensor): sid=%d error: %s", ui.name, sid, err))
+		}
+	}
+
+	// потом получаем текущее значение
+	// (заказ сделан раньше, поэтому изменения произошедшие после чтения не будут потеряны)
+	val, err := ui.backend.GetValue(sid)
 	if err != nil {
 		return nil, errors.New(fmt.Sprintf("%s (doAskSensor): error: %s", ui.name, err))
 	}
 
-	msg = &UMessage{&SensorEvent{sid, val, time.Now()}}
+	msg = &UMessage{&SensorEvent{Id: sid, Value: val, Timestamp: time.Now()}}
 
 	// вносим в список заказчиков
-	lst, found := ui.askmap[sid]
-	if found {
-		lst.add(cons)
-		return msg, nil
-	}
+	reg.add(sid, cons)
+
+	return msg, nil
+}
 
-	lst = newConsumersList()
+// ----------------------------------------------------------------------------------
+// обработка команды "отказ от заказа датчика"
+// Если заказчиков больше не осталось, отказываемся и от реального заказа
+func (ui *UProxy) doUnaskSensor(reg *askRegistry, sid ObjectID, id ObjectID) error {
 
-	if lst == nil {
-		return nil, err
+	if !reg.remove(sid, id) {
+		return nil
 	}
 
-	lst.add(cons)
-	ui.askmap[sid] = lst
+	ui.lockBackend()
+	defer ui.unlockBackend()
 
-	return msg, nil
+	err := ui.backend.UnaskSensor(sid)
+	if err != nil {
+		return errors.New(fmt.Sprintf("%s (doUnaskSensor): sid=%d error: %s", ui.name, sid, err))
+	}
+
+	return nil
 }
 
 // ----------------------------------------------------------------------------------
 // рассылка сообщений по списку
 func (ui *UProxy) sendMessage(msg *UMessage, l *consumersList) {
 
-	for e := l.list.Front(); e != nil; e = e.Next() {
-		c := e.Value.(UObject)
-		ui.send(c, *msg)
+	for _, d := range l.items {
+		d.send(*msg)
 	}
 }
 
 // ----------------------------------------------------------------------------------
-// посылка сообщения объекту
+// посылка сообщения объекту (в соответствии с его политикой доставки)
 func (ui *UProxy) send(obj UObject, msg UMessage) {
 
-	// делаем две попытки
-	for i := 0; i < 2; i++ {
-		select {
-		case obj.UEvent() <- msg:
-			return
-
-		default:
-		}
+	if d, found := ui.deliv[obj.ID()]; found {
+		d.send(msg)
+		return
 	}
-}
-
-// ----------------------------------------------------------------------------------
-func (l *consumersList) String() string {
 
-	var str string
-	str = "["
-	for e := l.list.Front(); e != nil; e = e.Next() {
-		c := e.Value.(UObject)
-		str = fmt.Sprintf("%s %d", str, c.ID())
+	select {
+	case obj.UEvent() <- msg:
+	default:
 	}
-	str += " ]"
-	return str
 }
 
 // ----------------------------------------------------------------------------------
-func (l *consumersList) add(cons UObject) {
+// закрытие канала событий объекта
+func (ui *UProxy) closeEvents(obj UObject) {
 
-	for e := l.list.Front(); e != nil; e = e.Next() {
-		c := e.Value.(UObject)
-		if c.ID() == cons.ID() {
-			return
-		}
+	if d, found := ui.deliv[obj.ID()]; found {
+		d.close()
+		return
 	}
 
-	l.list.PushBack(cons)
-}
-
-// ----------------------------------------------------------------------------------
-// внутренний список объектов
-type consumersList struct {
-	list list.List
+	close(obj.UEvent())
 }
 
 // ----------------------------------------------------------------------------------
-func newConsumersList() *consumersList {
-	lst := consumersList{}
-	return &lst
-}
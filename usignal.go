@@ -0,0 +1,100 @@
+// Обработка сигналов в UProxy.
+// По умолчанию (SignalTerminate) UProxy сам завершает работу по SIGINT/SIGTERM,
+// а по SIGHUP рассылает всем зарегистрированным объектам ReloadEvent.
+// Если у программы своя логика завершения, обработку можно отключить (SignalNone)
+// или передать пользовательской функции (SignalCallback, см. SetSignalCallback).
+// Политику необходимо задавать до вызова Run().
+package uniset
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// ----------------------------------------------------------------------------------
+type SignalPolicy int
+
+const (
+	SignalTerminate SignalPolicy = iota // SIGINT/SIGTERM - завершение работы, SIGHUP - ReloadEvent
+	SignalNone                          // сигналы не обрабатываются
+	SignalCallback                      // сигналы передаются пользовательской функции
+)
+
+// ----------------------------------------------------------------------------------
+// Задать политику обработки сигналов
+func (ui *UProxy) SetSignalPolicy(policy SignalPolicy) {
+	ui.runmutex.Lock()
+	defer ui.runmutex.Unlock()
+	ui.sigpolicy = policy
+}
+
+// ----------------------------------------------------------------------------------
+// Задать функцию обработки сигналов (SIGINT, SIGTERM, SIGHUP)
+// При этом политика обработки переключается в SignalCallback
+func (ui *UProxy) SetSignalCallback(fn func(sig os.Signal)) {
+	ui.runmutex.Lock()
+	defer ui.runmutex.Unlock()
+	ui.sigfunc = fn
+	ui.sigpolicy = SignalCallback
+}
+
+// ----------------------------------------------------------------------------------
+// Разослать всем зарегистрированным объектам ReloadEvent
+func (ui *UProxy) Reload() {
+
+	select {
+	case ui.reload <- struct{}{}:
+	default:
+		// уже есть не обработанный запрос
+	}
+}
+
+// ----------------------------------------------------------------------------------
+// запуск обработки сигналов (вызывается под runmutex)
+// go-рутина обработки завершается вместе с ctx
+func (ui *UProxy) startSignals(ctx context.Context, cancel context.CancelFunc) {
+
+	if ui.sigpolicy == SignalNone {
+		return
+	}
+
+	if ui.sigpolicy == SignalCallback && ui.sigfunc == nil {
+		return
+	}
+
+	policy := ui.sigpolicy
+	fn := ui.sigfunc
+
+	ch := make(chan os.Signal, 2)
+	signal.Notify(ch, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+
+	go func() {
+
+		defer signal.Stop(ch)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+
+			case sig := <-ch:
+
+				if policy == SignalCallback {
+					fn(sig)
+					continue
+				}
+
+				switch sig {
+				case syscall.SIGHUP:
+					ui.Reload()
+				default:
+					cancel()
+				}
+			}
+		}
+	}()
+}
+
+// ----------------------------------------------------------------------------------
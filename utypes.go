@@ -4,7 +4,6 @@ package uniset
 import (
 	"fmt"
 	"time"
-	"uniset_internal_api"
 )
 
 type ObjectID int64
@@ -37,11 +36,20 @@ type ActivateEvent struct {
 type FinishEvent struct {
 }
 
+// ----------------------------------------------------------------------------------
+// уведомление о необходимости перечитать настройки, переоткрыть логи и т.п.
+// (рассылается по SIGHUP, аналогично команде logrotate)
+type ReloadEvent struct {
+}
+
 // ----------------------------------------------------------------------------------
 type SensorEvent struct {
 	Id        ObjectID
 	Value     int64
 	Timestamp time.Time
+	// текущее значение, досылаемое после потери событий (см. DeliveryPolicy)
+	// Timestamp у таких событий - время досылки, а не время изменения датчика
+	Resync bool
 }
 
 // ----------------------------------------------------------------------------------
@@ -50,6 +58,13 @@ type AskCommand struct {
 	Result bool
 }
 
+// ----------------------------------------------------------------------------------
+// отказ от заказа датчика
+type UnaskCommand struct {
+	Id     ObjectID
+	Result bool
+}
+
 // ----------------------------------------------------------------------------------
 type SetValueCommand struct {
 	Id     ObjectID
@@ -59,28 +74,48 @@ type SetValueCommand struct {
 
 // ----------------------------------------------------------------------------------
 // связывание sensor id и bool-поля структуры
-// для формирования списков входов и выходов
-type BoolValue struct {
-	Sid  *ObjectID
-	Val  *bool
-	prev bool
-}
+// для формирования списков входов и выходов (см. Value)
+type BoolValue = Value[bool]
 
 func NewBoolValue(sid *ObjectID, val *bool) *BoolValue {
-	return &BoolValue{sid, val, *val}
+	return NewValue(sid, val, BoolCodec{})
 }
 
 // ----------------------------------------------------------------------------------
 // связывание sensor id и int64-поля структуры
-// для формирования списков входов и выходов
-type Int64Value struct {
-	Sid  *ObjectID
-	Val  *int64
-	prev int64
-}
+// для формирования списков входов и выходов (см. Value)
+type Int64Value = Value[int64]
 
 func NewInt64Value(sid *ObjectID, val *int64) *Int64Value {
-	return &Int64Value{sid, val, *val}
+	return NewValue(sid, val, IntCodec[int64]{})
+}
+
+// ----------------------------------------------------------------------------------
+// связывание sensor id и float64-поля структуры
+// для формирования списков входов и выходов (см. Value, FloatCodec)
+type Float64Value = Value[float64]
+
+// precision - количество знаков после запятой (отрицательные значения считаются нулём)
+func NewFloat64Value(sid *ObjectID, val *float64, precision int) *Float64Value {
+	return NewValue(sid, val, NewFloatCodec[float64](precision))
+}
+
+// ----------------------------------------------------------------------------------
+// Создание с точностью указанной для датчика в configure.xml (атрибут precision)
+// К моменту вызова идентификатор датчика (*sid) уже должен быть известен
+func NewFloat64ValueFromConfig(sid *ObjectID, val *float64) (*Float64Value, error) {
+
+	si, err := SensorInfoByID(*sid)
+	if err != nil {
+		return nil, err
+	}
+
+	prec, err := si.Precision()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewFloat64Value(sid, val, prec), nil
 }
 
 // ----------------------------------------------------------------------------------
@@ -116,6 +151,22 @@ func (u *UMessage) PopAsAskCommand() (*AskCommand, bool) {
 	return nil, false
 }
 
+// ----------------------------------------------------------------------------------
+func (u *UMessage) PopAsUnaskCommand() (*UnaskCommand, bool) {
+	switch u.Msg.(type) {
+
+	case UnaskCommand:
+		c := u.Msg.(UnaskCommand)
+		return &c, true
+
+	case *UnaskCommand:
+		c := u.Msg.(*UnaskCommand)
+		return c, true
+	}
+
+	return nil, false
+}
+
 // ----------------------------------------------------------------------------------
 func (u *UMessage) PopAsSetValueCommand() (*SetValueCommand, bool) {
 	switch u.Msg.(type) {
@@ -165,17 +216,28 @@ func (u *UMessage) PopAsFinishEvent() (*FinishEvent, bool) {
 }
 
 // ----------------------------------------------------------------------------------
-func (m *SensorEvent) String() string {
-	return fmt.Sprintf("id: %d value: %d", m.Id, m.Value)
+func (u *UMessage) PopAsReloadEvent() (*ReloadEvent, bool) {
+	switch u.Msg.(type) {
+
+	case ReloadEvent:
+		c := u.Msg.(ReloadEvent)
+		return &c, true
+
+	case *ReloadEvent:
+		c := u.Msg.(*ReloadEvent)
+		return c, true
+	}
+
+	return nil, false
 }
 
 // ----------------------------------------------------------------------------------
-func makeSensorEvent(m uniset_internal_api.ShortIOInfo) *SensorEvent {
-	var msg SensorEvent
-	msg.Id = ObjectID(m.GetId())
-	msg.Value = m.GetValue()
-	msg.Timestamp = time.Unix(m.GetTv_sec(), m.GetTv_nsec())
-	return &msg
+func (m *SensorEvent) String() string {
+	if m.Resync {
+		return fmt.Sprintf("id: %d value: %d (resync)", m.Id, m.Value)
+	}
+
+	return fmt.Sprintf("id: %d value: %d", m.Id, m.Value)
 }
 
 // ----------------------------------------------------------------------------------
@@ -0,0 +1,101 @@
+// Конфигурация (configure.xml) разобранная go-частью (см. пакет config).
+// Загружается при вызове Init() и используется там, где c++-часть
+// не предоставляет нужной информации (SensorInfo, калибровки и т.п.)
+package uniset
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"uniset/config"
+)
+
+var (
+	defConf    *config.Config
+	defConfErr error
+	defConfMut sync.RWMutex
+)
+
+// ----------------------------------------------------------------------------------
+// Конфигурация загруженная при вызове Init()
+// (nil - если Init() не вызывался или при загрузке была ошибка)
+func DefaultConfig() *config.Config {
+	defConfMut.RLock()
+	defer defConfMut.RUnlock()
+	return defConf
+}
+
+// ----------------------------------------------------------------------------------
+// Задать конфигурацию используемую по умолчанию
+// (например, в тестах без вызова Init)
+func SetDefaultConfig(conf *config.Config) {
+	defConfMut.Lock()
+	defer defConfMut.Unlock()
+	defConf = conf
+	defConfErr = nil
+}
+
+// ----------------------------------------------------------------------------------
+func loadDefaultConfig(confile string) error {
+
+	conf, err := config.Load(confile)
+
+	defConfMut.Lock()
+	defer defConfMut.Unlock()
+	defConf = conf
+	defConfErr = err
+	return err
+}
+
+// ----------------------------------------------------------------------------------
+func sensorIDFromConfig(name string) ObjectID {
+
+	conf := DefaultConfig()
+	if conf == nil {
+		return DefaultObjectID
+	}
+
+	return ObjectID(conf.SensorID(name))
+}
+
+// ----------------------------------------------------------------------------------
+func objectIDFromConfig(name string) ObjectID {
+
+	conf := DefaultConfig()
+	if conf == nil {
+		return DefaultObjectID
+	}
+
+	return ObjectID(conf.ObjectID(name))
+}
+
+// ----------------------------------------------------------------------------------
+func configParamsFromConfig(name string, section string) (*UConfig, error) {
+
+	conf, err := requireConfig()
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("(GetConfigParamsByName): %s", err))
+	}
+
+	return GetConfigParamsFromXML(conf, name, section)
+}
+
+// ----------------------------------------------------------------------------------
+// получение конфигурации с описанием ошибки, если её нет
+func requireConfig() (*config.Config, error) {
+
+	defConfMut.RLock()
+	defer defConfMut.RUnlock()
+
+	if defConf != nil {
+		return defConf, nil
+	}
+
+	if defConfErr != nil {
+		return nil, fmt.Errorf("(uniset): configuration is not loaded: %s", defConfErr)
+	}
+
+	return nil, errors.New("(uniset): configuration is not loaded (see Init, SetDefaultConfig)")
+}
+
+// ----------------------------------------------------------------------------------